@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"netmonitor/pkg/config"
+	"os"
+)
+
+const configUsage = "用法: netmonitor config check|print|init [选项]"
+
+// runConfig 执行 config 子命令,返回进程退出码
+func runConfig(cfgPath string, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "check":
+			return runConfigCheck(cfgPath, args[1:])
+		case "print":
+			return runConfigPrint(cfgPath, args[1:])
+		case "init":
+			return runConfigInit(cfgPath, args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, configUsage)
+	return 2
+}
+
+// runConfigCheck 检查生效的配置但不启动监控: 无法识别的配置项作为警告,取值无效时返回1
+func runConfigCheck(cfgPath string, args []string) int {
+	fs := newFlagSet("config check", configUsage, &cfgPath)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// 配置文件不存在时使用默认值(以及环境变量和选项),与 run 一致
+	if _, err := os.Stat(cfgPath); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: 警告: %v,使用默认配置\n", cfgPath, err)
+	}
+	// 不经过 loadConfig,以便在取值无效时也先输出警告
+	cfg, err := config.Load(cfgPath, overrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cfgPath, err)
+		return 1
+	}
+
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "%s: 警告: %s\n", problemPos(cfgPath, w), w.Message)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		reportConfigError(cfgPath, err)
+		return 1
+	}
+
+	fmt.Printf("%s: 配置有效\n", cfgPath)
+	return 0
+}
+
+// runConfigPrint 以TOML格式输出配置。默认输出内置默认值,可作为配置文件模板;
+// -effective 输出合并配置文件、环境变量和选项之后实际生效的配置,并注明每项的来源
+func runConfigPrint(cfgPath string, args []string) int {
+	fs := newFlagSet("config print", "用法: netmonitor config print [-effective] [选项]", &cfgPath)
+	effective := fs.Bool("effective", false, "输出实际生效的配置及每项的来源(default, file, env, flag)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := config.Default()
+	if *effective {
+		var err error
+		// 输出实际生效的取值,即使其中有无效的取值
+		if cfg, err = config.Load(cfgPath, overrides); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cfgPath, err)
+			return 1
+		}
+	}
+	cfg.Print(os.Stdout, *effective)
+	return 0
+}
+
+// runConfigInit 以内置默认值创建配置文件,文件已存在时不覆盖
+func runConfigInit(cfgPath string, args []string) int {
+	fs := newFlagSet("config init", configUsage, &cfgPath)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := config.InitConfig(cfgPath); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			fmt.Fprintf(os.Stderr, "%s: 配置文件已存在,未覆盖\n", cfgPath)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: 创建配置文件失败: %v\n", cfgPath, err)
+		}
+		return 1
+	}
+	fmt.Printf("%s: 已写入默认配置\n", cfgPath)
+	return 0
+}
+
+// problemPos 问题的位置: 文件:行号: 配置项;取值来自环境变量或命令行选项时给出其名称,
+// 文件中未设置的配置项不给出行号
+func problemPos(cfgPath string, p config.Problem) string {
+	switch {
+	case p.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", cfgPath, p.Line, p.Key)
+	case p.Origin != "":
+		return fmt.Sprintf("%s: %s", p.Origin, p.Key)
+	}
+	return fmt.Sprintf("%s: %s", cfgPath, p.Key)
+}
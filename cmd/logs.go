@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"netmonitor/pkg/logger"
+	"os"
+	"strings"
+	"time"
+)
+
+const logsUsage = "用法: netmonitor logs search [选项]"
+
+// runLogs 执行 logs 子命令,返回进程退出码
+func runLogs(cfgPath string, args []string) int {
+	if len(args) == 0 || args[0] != "search" {
+		fmt.Fprintln(os.Stderr, logsUsage)
+		return 2
+	}
+	return runLogSearch(cfgPath, args[1:])
+}
+
+// runLogSearch 搜索 listener_dir 和 established_dir 中的日志(包括 .log.gz)
+func runLogSearch(cfgPath string, args []string) int {
+	fs := newFlagSet("logs search", logsUsage, &cfgPath)
+	from := fs.String("from", "", `起始时间: 2006-01-02 或 "2006-01-02 15:04:05"`)
+	to := fs.String("to", "", "结束时间,只给出日期时包含当天")
+	pid := fs.Int("pid", 0, "进程PID")
+	process := fs.String("process", "", "进程名(不区分大小写的子串)")
+	addr := fs.String("addr", "", "本地或远程地址: IP、CIDR、IP:端口 或 :端口")
+	direction := fs.String("direction", "", "事件方向: opened, closed,或事件类型(如 state_changed, alert)")
+	source := fs.String("source", "", "只搜索 listener 或 established 日志")
+	format := fs.String("format", "text", "输出格式: text, json")
+	limit := fs.Int("limit", 0, "最大条数(0表示不限)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "未知的输出格式: %s\n", *format)
+		return 2
+	}
+
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		reportConfigError(cfgPath, err)
+		return 1
+	}
+
+	opts := logger.SearchOptions{
+		PID:       int32(*pid),
+		Process:   *process,
+		Addr:      *addr,
+		Direction: *direction,
+		Limit:     *limit,
+	}
+	if opts.From, err = parseSearchTime(*from, false); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if opts.To, err = parseSearchTime(*to, true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	switch strings.ToLower(*source) {
+	case "":
+		opts.Dirs = []logger.SearchDir{
+			{Source: logger.SourceListener, Dir: cfg.Log.ListenerDir},
+			{Source: logger.SourceEstablished, Dir: cfg.Log.EstablishedDir},
+		}
+	case logger.SourceListener:
+		opts.Dirs = []logger.SearchDir{{Source: logger.SourceListener, Dir: cfg.Log.ListenerDir}}
+	case logger.SourceEstablished:
+		opts.Dirs = []logger.SearchDir{{Source: logger.SourceEstablished, Dir: cfg.Log.EstablishedDir}}
+	default:
+		fmt.Fprintf(os.Stderr, "未知的日志来源: %s\n", *source)
+		return 2
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+
+	err = logger.Search(opts, func(e logger.Entry) bool {
+		if *format == "json" {
+			enc.Encode(e)
+		} else {
+			fmt.Fprintf(out, "%-11s %s\n", e.Source, e.Text())
+		}
+		return true
+	})
+	if err != nil {
+		out.Flush()
+		fmt.Fprintf(os.Stderr, "搜索日志失败: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseSearchTime 解析命令行中的时间;只给出日期且 endOfDay 为true时取当天结束
+func parseSearchTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的时间: %s", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
@@ -1,242 +1,215 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net/netip"
 	"netmonitor/pkg/config"
 	"netmonitor/pkg/logger"
-	"netmonitor/pkg/monitor"
 	"netmonitor/pkg/netinfo"
-	"netmonitor/pkg/web"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
-	"time"
 )
 
-func main() {
-	// 初始化配置
-	cfgPath := filepath.Join("config", "config.toml")
-	if err := config.InitConfig(cfgPath); err != nil {
-		panic(fmt.Sprintf("初始化配置失败: %v", err))
-	}
+const usageText = `用法: netmonitor [--config 配置文件] <命令> [选项]
 
-	cfg, err := config.LoadConfig(cfgPath)
-	if err != nil {
-		panic(fmt.Sprintf("加载配置失败: %v", err))
-	}
+命令:
+  run        持续监控网络连接并写入日志(默认)
+  snapshot   输出当前连接列表(table/json/csv)
+  watch      持续监控,只把事件输出到标准输出,不写日志文件
+  tui        全屏终端界面: 连接表、进程汇总、事件窗格和过滤条件编辑
+  diff       比较两份保存的快照
+  logs       搜索日志文件(logs search)
+  config     检查配置文件(config check)或输出配置(config print)
 
-	// 初始化日志
-	if err := logger.InitLogger(cfg.Log.ListenerDir, cfg.Log.EstablishedDir,
-		cfg.Log.ColorEnabled, cfg.Monitor.LogToConsole); err != nil {
-		panic(fmt.Sprintf("初始化日志失败: %v", err))
-	}
+使用 "netmonitor <命令> -h" 查看命令的选项
 
-	// 启动日志清理任务
-	cleanupConfig := logger.CleanupConfig{
-		Enabled:          true,
-		RetentionDays:   cfg.Log.RetentionDays,
-		CompressEnabled: cfg.Log.AutoCompress,
-	}
-	logger.StartCleanupTask(cfg.Log.ListenerDir, cfg.Log.EstablishedDir, cleanupConfig)
+每个配置项都可以用环境变量 NETMONITOR_<段>_<键> 或选项 -<段>.<键> 覆盖,
+优先级: 默认值 < 配置文件 < 环境变量 < 命令行选项。例如:
+  NETMONITOR_MONITOR_INTERVAL=5 netmonitor run -web.enabled -web.port 9090
+`
 
-	// 创建过滤器
-	filter := &netinfo.ConnectionFilter{
-		ProcessName: cfg.Filter.ProcessName,
-		PIDs:        cfg.Filter.PIDs,
-		Protocols:   cfg.Filter.Protocols,
-		RemoteIP:    cfg.Filter.RemoteIP,
-	}
-
-	// 打印启动信息
-	printStartupInfo(cfg, filter)
-
-	// 初始化监控器
-	listenerMon := monitor.NewListenerMonitor(filter)
-	if err := listenerMon.Initialize(); err != nil {
-		panic(err)
-	}
-
-	establishedMon := monitor.NewEstablishedMonitor(filter)
-	if err := establishedMon.Initialize(); err != nil {
-		panic(err)
-	}
-
-	// 初始化统计
-	stats := monitor.NewStats()
-
-	// 初始化Web服务器(如果启用)
-	var webServer *web.Server
-	if cfg.Web.Enabled {
-		webServer = web.NewServer(cfg.Web.Port)
-		webServer.SetStats(stats)
-		webServer.SetFilter(filter)
-
-		// 预加载连接数据
-		allConns, _ := netinfo.GetConnections()
-		webServer.UpdateConnections(allConns)
-
-		go func() {
-			if err := webServer.Start(); err != nil {
-				logger.LogWarning(os.Stdout, fmt.Sprintf("Web服务器启动失败: %v", err))
-			}
-		}()
-		time.Sleep(100 * time.Millisecond) // 等待Web服务器启动
-	}
-
-	// 设置优雅退出
-	setupExitHandler()
-
-	// 启动定时检测
-	ticker := time.NewTicker(cfg.Monitor.GetInterval())
-	defer ticker.Stop()
-
-	// 统计显示定时器
-	var statsTicker *time.Ticker
-	if cfg.Monitor.ShowStats {
-		statsTicker = time.NewTicker(10 * time.Second)
-		defer statsTicker.Stop()
-	}
-
-	for {
-		select {
-		case <-ticker.C:
-			// 监听端口检测
-			newListeners, closedListeners, err := listenerMon.CheckChanges()
-			if err != nil {
-				logger.LogWarning(logger.ListenerWriter, fmt.Sprintf("监听端口检测错误: %v", err))
-				continue
-			}
-
-			if len(newListeners) > 0 {
-				listenerMon.LogNewListeners(newListeners)
-				for _, l := range newListeners {
-					stats.RecordNewListener(l.Protocol, l.PID)
-					if webServer != nil {
-						webServer.BroadcastNewConnection(l)
-					}
-				}
-			}
-
-			if len(closedListeners) > 0 {
-				listenerMon.LogClosedListeners(closedListeners)
-				for _, l := range closedListeners {
-					stats.RecordClosedListener(l.Protocol, l.PID)
-					if webServer != nil {
-						webServer.BroadcastClosedConnection(l)
-					}
-				}
-			}
-
-			// 已建立连接检测
-			newEstablished, closedEstablished, err := establishedMon.CheckChanges()
-			if err != nil {
-				logger.LogWarning(logger.EstablishedWriter, fmt.Sprintf("已建立连接检测错误: %v", err))
-				continue
-			}
-
-			if len(newEstablished) > 0 {
-				establishedMon.LogNewConnections(newEstablished)
-				for _, c := range newEstablished {
-					stats.RecordNewConnection(c.Protocol, c.PID)
-					if webServer != nil {
-						webServer.BroadcastNewConnection(c)
-					}
-				}
-			}
-
-			if len(closedEstablished) > 0 {
-				establishedMon.LogClosedConnections(closedEstablished)
-				for _, c := range closedEstablished {
-					stats.RecordClosedConnection(c.Protocol, c.PID)
-					if webServer != nil {
-						webServer.BroadcastClosedConnection(c)
-					}
-				}
-			}
-
-			// 更新统计信息
-			allConns, _ := netinfo.GetConnections()
-			stats.Update(allConns)
-
-			// 更新Web服务器的连接列表
-			if webServer != nil {
-				webServer.UpdateConnections(allConns)
-			}
-
-		case <-statsTicker.C:
-			// 显示统计信息
-			logger.LogInfo(os.Stdout, stats.GetDisplay())
+// overrides 命令行中通过 -<段>.<键> 指定的配置项
+var overrides config.Overrides
+
+func main() {
+	defaultPath := filepath.Join("config", "config.toml")
+	if path := os.Getenv(config.EnvPrefix + "CONFIG"); path != "" {
+		defaultPath = path
+	}
+	cfgPath := flag.String("config", defaultPath, "配置文件路径(环境变量 NETMONITOR_CONFIG)")
+	overrides.Register(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usageText)
+		fmt.Fprintln(flag.CommandLine.Output(), "\n全局选项:")
+		printFlags(flag.CommandLine)
+	}
+	flag.Parse()
+
+	command, args := "run", flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	var code int
+	switch command {
+	case "run":
+		code = runDaemon(*cfgPath, args)
+	case "snapshot":
+		code = runSnapshot(*cfgPath, args)
+	case "watch":
+		code = runWatch(*cfgPath, args)
+	case "tui":
+		code = runTUI(*cfgPath, args)
+	case "diff":
+		code = runDiff(*cfgPath, args)
+	case "logs":
+		code = runLogs(*cfgPath, args)
+	case "config":
+		code = runConfig(*cfgPath, args)
+	case "help":
+		flag.Usage()
+	default:
+		fmt.Fprintf(os.Stderr, "未知的命令: %s\n\n", command)
+		flag.Usage()
+		code = 2
+	}
+	os.Exit(code)
+}
+
+// newFlagSet 创建子命令的选项集合,子命令中也可以用 -config 覆盖全局的配置文件路径
+func newFlagSet(name, usage string, cfgPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		if usage == "" {
+			usage = "用法: netmonitor " + name + " [选项]"
 		}
+		fmt.Fprintln(fs.Output(), usage)
+		printFlags(fs)
 	}
+	fs.StringVar(cfgPath, "config", *cfgPath, "配置文件路径")
+	overrides.Register(fs)
+	return fs
 }
 
-func printStartupInfo(cfg *config.Config, filter *netinfo.ConnectionFilter) {
-	fmt.Println("========================================")
-	fmt.Println("       网络连接监控器已启动")
-	fmt.Println("========================================")
-	fmt.Printf("检测间隔: %d 秒\n", cfg.Monitor.Interval)
-	fmt.Printf("统计显示: %s\n", getBoolString(cfg.Monitor.ShowStats))
-	fmt.Printf("彩色输出: %s\n", getBoolString(cfg.Log.ColorEnabled))
-	fmt.Println("\n过滤配置:")
-	fmt.Printf("  进程名称: %s\n", getStringOrDefault(filter.ProcessName, "全部"))
-	fmt.Printf("  PID过滤: %s\n", getPIDsString(filter.PIDs))
-	fmt.Printf("  协议类型: %s\n", getProtocolsString(filter.Protocols))
-	fmt.Printf("  远程IP: %s\n", getStringOrDefault(filter.RemoteIP, "全部"))
-	fmt.Println("========================================\n")
-	logger.LogInfo(os.Stdout, "开始监控网络连接...")
+// printFlags 输出选项说明。配置项选项(-<段>.<键>)数量较多,只给出一行提示
+func printFlags(fs *flag.FlagSet) {
+	display := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	display.SetOutput(fs.Output())
+	fs.VisitAll(func(f *flag.Flag) {
+		if !config.IsOverrideFlag(f.Name) {
+			display.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	display.PrintDefaults()
+	fmt.Fprintln(fs.Output(), "  -<段>.<键> 值\n    \t覆盖配置项,例如 -monitor.interval 5、-web.enabled;全部配置项见 netmonitor config print")
 }
 
-func getBoolString(b bool) string {
-	if b {
-		return "启用"
+// loadConfig 加载配置文件,并应用环境变量和命令行中的配置项。取值无效时返回 *config.ValidationError
+func loadConfig(cfgPath string) (*config.Config, error) {
+	cfg, err := config.Load(cfgPath, overrides)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
-	return "禁用"
+	return cfg, nil
 }
 
-func getStringOrDefault(s, def string) string {
-	if s == "" {
-		return def
+// reportConfigError 输出加载配置失败的原因,取值无效时逐项给出 文件:行号: 配置项
+func reportConfigError(cfgPath string, err error) {
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) {
+		fmt.Fprintf(os.Stderr, "%s: 加载配置失败: %v\n", cfgPath, err)
+		return
+	}
+	for _, p := range verr.Problems {
+		fmt.Fprintf(os.Stderr, "%s: 错误: %s\n", problemPos(cfgPath, p), p.Message)
 	}
-	return s
+	fmt.Fprintf(os.Stderr, "发现 %d 个错误\n", len(verr.Problems))
 }
 
-func getPIDsString(pids []int32) string {
-	if len(pids) == 0 {
-		return "全部"
+// newFilter 根据 [filter] 配置创建连接过滤器,进程规则、网段和过滤表达式在这里解析
+func newFilter(cfg *config.Config) (*netinfo.ConnectionFilter, error) {
+	filter := &netinfo.ConnectionFilter{
+		PIDs:      cfg.Filter.PIDs,
+		Protocols: cfg.Filter.Protocols,
+		Families:  cfg.Filter.Families,
+		States:    cfg.Filter.States,
+
+		RemotePorts:        cfg.Filter.RemotePorts,
+		ExcludeRemotePorts: cfg.Filter.ExcludeRemotePorts,
+		LocalPorts:         cfg.Filter.LocalPorts,
+		ExcludeLocalPorts:  cfg.Filter.ExcludeLocalPorts,
+	}
+
+	// process_name 和 remote_ip 是较早的单个取值配置,分别与 processes 和 remote_nets 合并
+	processes := cfg.Filter.Processes
+	if cfg.Filter.ProcessName != "" {
+		processes = append([]string{cfg.Filter.ProcessName}, processes...)
+	}
+	var err error
+	if filter.Processes, err = netinfo.ParseProcessMatchers(processes); err != nil {
+		return nil, err
+	}
+	if filter.ExcludeProcesses, err = netinfo.ParseProcessMatchers(cfg.Filter.ExcludeProcesses); err != nil {
+		return nil, err
+	}
+
+	remoteNets := cfg.Filter.RemoteNets
+	if cfg.Filter.RemoteIP != "" {
+		remoteNets = append([]string{cfg.Filter.RemoteIP}, remoteNets...)
+	}
+	nets := []struct {
+		list []string
+		dst  *[]netip.Prefix
+	}{
+		{remoteNets, &filter.RemoteNets},
+		{cfg.Filter.ExcludeRemoteNets, &filter.ExcludeRemoteNets},
+		{cfg.Filter.LocalNets, &filter.LocalNets},
+		{cfg.Filter.ExcludeLocalNets, &filter.ExcludeLocalNets},
+	}
+	for _, n := range nets {
+		prefixes, err := netinfo.ParsePrefixes(n.list)
+		if err != nil {
+			return nil, err
+		}
+		*n.dst = prefixes
 	}
-	result := ""
-	for i, pid := range pids {
-		if i > 0 {
-			result += ", "
+
+	if cfg.Filter.Expr != "" {
+		expr, err := netinfo.ParseFilterExpr(cfg.Filter.Expr)
+		if err != nil {
+			return nil, err
 		}
-		result += fmt.Sprintf("%d", pid)
+		filter.Expr = expr
 	}
-	return result
+	return filter, nil
 }
 
-func getProtocolsString(protocols []string) string {
-	if len(protocols) == 0 {
-		return "全部"
+// newCollector 根据配置创建采集器(回放、录制或实时采集),回退等警告输出到 warn
+func newCollector(cfg *config.Config, warn io.Writer) (netinfo.Collector, error) {
+	if cfg.Monitor.ReplayFile != "" {
+		return netinfo.LoadReplay(cfg.Monitor.ReplayFile)
 	}
-	result := ""
-	for i, p := range protocols {
-		if i > 0 {
-			result += ", "
+
+	collector, err := netinfo.NewCollector(cfg.Monitor.Collector, cfg.Monitor.NetlinkStates)
+	if err != nil {
+		if !errors.Is(err, netinfo.ErrNetlinkUnavailable) {
+			return nil, err
 		}
-		result += p
+		logger.LogWarning(warn, fmt.Sprintf("采集后端: %v", err))
 	}
-	return result
-}
 
-func setupExitHandler() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		sig := <-sigChan
-		fmt.Printf("\n收到信号 %v, 正在退出...\n", sig)
-		logger.LogInfo(os.Stdout, "监控器已停止")
-		os.Exit(0)
-	}()
+	if cfg.Monitor.RecordFile != "" {
+		f, err := os.OpenFile(cfg.Monitor.RecordFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("打开录制文件失败: %w", err)
+		}
+		collector = netinfo.NewRecordingCollector(collector, f)
+	}
+	return collector, nil
 }
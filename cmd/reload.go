@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"netmonitor/pkg/config"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/history"
+	"netmonitor/pkg/logger"
+	"netmonitor/pkg/monitor"
+	"netmonitor/pkg/netinfo"
+	"netmonitor/pkg/web"
+	"os"
+	"strings"
+	"time"
+)
+
+// configWatchInterval 检查配置文件是否被修改的间隔
+const configWatchInterval = 2 * time.Second
+
+// restartKeys 运行中无法替换的配置项(或配置段),修改后需要重启才能生效
+var restartKeys = []string{
+	"log.listener_dir",
+	"log.established_dir",
+	"monitor.collector",
+	"monitor.record_file",
+	"monitor.replay_file",
+	"monitor.netlink_states",
+	"syslog.",
+	"history.",
+}
+
+// daemon run 模式中可以在运行时按新配置调整的组件,只在主循环所在的goroutine中使用
+type daemon struct {
+	cfgPath    string
+	cfg        *config.Config // 当前生效的配置
+	dispatcher *monitor.Dispatcher
+	filter     *netinfo.ConnectionFilter // 当前使用的过滤器,重新加载时整体替换
+	bus        *event.Bus
+	stats      *monitor.Stats
+	store      *history.Store
+	web        *web.Server // 首次启用Web界面时创建,停用后保留以便再次启用
+	ticker     *time.Ticker
+	snap       *netinfo.Snapshot // 最近一次采集的快照
+}
+
+// startWeb 在后台启动Web服务器
+func (d *daemon) startWeb() {
+	if d.web == nil {
+		d.web = web.NewServer(d.cfg.Web.Port)
+		d.web.SetStats(d.stats)
+		d.web.SetFilter(d.filter)
+		d.web.SetBus(d.bus)
+		d.web.SetHistory(d.store)
+		d.web.SetLogDirs(d.cfg.Log.ListenerDir, d.cfg.Log.EstablishedDir)
+
+		// 预加载连接数据
+		d.web.UpdateConnections(d.snap)
+		d.bus.Consume(d.bus.Subscribe("web", event.DefaultQueueSize), d.web.HandleEvent)
+	} else {
+		d.web.SetPort(d.cfg.Web.Port)
+		d.web.UpdateConnections(d.snap)
+	}
+
+	srv := d.web
+	go func() {
+		if err := srv.Start(); err != nil {
+			logger.LogWarning(os.Stdout, fmt.Sprintf("Web服务器启动失败: %v", err))
+		}
+	}()
+	time.Sleep(100 * time.Millisecond) // 等待Web服务器启动
+}
+
+// stopWeb 关闭Web服务器的监听
+func (d *daemon) stopWeb() {
+	if d.web == nil {
+		return
+	}
+	if err := d.web.Stop(); err != nil {
+		logger.LogWarning(os.Stdout, fmt.Sprintf("关闭Web服务器失败: %v", err))
+	}
+}
+
+// reload 重新加载配置文件。配置无法解析或取值无效时保留当前配置;
+// 需要重启才能生效的配置项继续使用当前值,并提示需要重启
+func (d *daemon) reload(reason string) {
+	cfg, err := loadConfig(d.cfgPath)
+	var filter *netinfo.ConnectionFilter
+	if err == nil {
+		filter, err = newFilter(cfg)
+	}
+	if err != nil {
+		logger.LogWarning(os.Stdout, fmt.Sprintf("重新加载配置失败(%s),继续使用当前配置: %v", reason, err))
+		return
+	}
+
+	for _, w := range cfg.Warnings() {
+		logger.LogWarning(os.Stdout, fmt.Sprintf("配置文件 %s: %s", d.cfgPath, w))
+	}
+
+	var applied, pending []string
+	for _, c := range config.Diff(d.cfg, cfg) {
+		if needsRestart(c.Key) {
+			pending = append(pending, c.String())
+		} else {
+			applied = append(applied, c.String())
+		}
+	}
+	if len(applied) == 0 && len(pending) == 0 {
+		logger.LogInfo(os.Stdout, fmt.Sprintf("配置已重新加载(%s),没有变化", reason))
+		return
+	}
+
+	keepRestartSettings(d.cfg, cfg)
+	old := d.cfg
+	d.cfg = cfg
+	d.filter = filter
+	d.apply(old)
+
+	if len(applied) > 0 {
+		logger.LogInfo(os.Stdout, fmt.Sprintf("配置已重新加载(%s): %s", reason, strings.Join(applied, "; ")))
+	}
+	if len(pending) > 0 {
+		logger.LogWarning(os.Stdout, fmt.Sprintf("以下配置需要重启才能生效: %s", strings.Join(pending, "; ")))
+	}
+}
+
+// apply 让 d.cfg 中可以运行时修改的配置生效
+func (d *daemon) apply(old *config.Config) {
+	cfg := d.cfg
+
+	// 过滤器整体替换,Web请求可能同时在读取旧的过滤器
+	d.dispatcher.SetFilter(d.filter)
+	if d.web != nil {
+		d.web.SetFilter(d.filter)
+	}
+	d.dispatcher.TrackStates = cfg.Monitor.TrackStates
+	d.dispatcher.State.SetCloseWaitThreshold(cfg.Monitor.CloseWaitThreshold)
+	if cfg.Monitor.Interval != old.Monitor.Interval {
+		d.ticker.Reset(cfg.Monitor.GetInterval())
+	}
+
+	logger.ColorEnabled.Store(cfg.Log.ColorEnabled)
+	logger.SetConsole(cfg.Monitor.LogToConsole)
+	if err := logger.SetFormat(cfg.Log.Format); err != nil {
+		logger.LogWarning(os.Stdout, err.Error())
+	}
+	logger.SetMaxFileSize(maxLogFileSize(cfg))
+	logger.SetCleanupConfig(newCleanupConfig(cfg))
+
+	switch {
+	case cfg.Web.Enabled && !old.Web.Enabled:
+		d.startWeb()
+	case !cfg.Web.Enabled && old.Web.Enabled:
+		d.stopWeb()
+	case cfg.Web.Enabled && cfg.Web.Port != old.Web.Port:
+		d.stopWeb()
+		d.startWeb()
+	}
+}
+
+func needsRestart(key string) bool {
+	for _, k := range restartKeys {
+		if key == k || strings.HasSuffix(k, ".") && strings.HasPrefix(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepRestartSettings 将需要重启才能生效的配置项恢复为当前值,使 cfg 反映实际生效的配置
+func keepRestartSettings(current, cfg *config.Config) {
+	cfg.Log.ListenerDir = current.Log.ListenerDir
+	cfg.Log.EstablishedDir = current.Log.EstablishedDir
+	cfg.Monitor.Collector = current.Monitor.Collector
+	cfg.Monitor.RecordFile = current.Monitor.RecordFile
+	cfg.Monitor.ReplayFile = current.Monitor.ReplayFile
+	cfg.Monitor.NetlinkStates = current.Monitor.NetlinkStates
+	cfg.Syslog = current.Syslog
+	cfg.History = current.History
+}
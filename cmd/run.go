@@ -0,0 +1,361 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"netmonitor/pkg/config"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/history"
+	"netmonitor/pkg/logger"
+	"netmonitor/pkg/monitor"
+	"netmonitor/pkg/netinfo"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// runDaemon 持续监控网络连接,写入日志并按配置启用syslog、历史库和Web界面
+func runDaemon(cfgPath string, args []string) int {
+	fs := newFlagSet("run", "", &cfgPath)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		reportConfigError(cfgPath, err)
+		return 1
+	}
+
+	for _, w := range cfg.Warnings() {
+		logger.LogWarning(os.Stdout, fmt.Sprintf("配置文件 %s: %s", cfgPath, w))
+	}
+
+	// 初始化syslog输出(如果启用)。LogWarning 会读取 logger.Syslog,
+	// 因此在启动日志切分、清理等任何goroutine之前设置
+	if cfg.Syslog.Enabled {
+		sink, err := logger.NewSyslogSink(logger.SyslogOptions{
+			Network:  cfg.Syslog.Network,
+			Address:  cfg.Syslog.Address,
+			Facility: cfg.Syslog.Facility,
+			AppName:  cfg.Syslog.Tag,
+		})
+		if err != nil {
+			logger.LogWarning(os.Stdout, fmt.Sprintf("syslog输出初始化失败: %v", err))
+		} else {
+			logger.Syslog = sink
+		}
+	}
+
+	// 初始化日志,切分出的旧文件立即交给清理任务压缩
+	rotate := logger.RotateOptions{
+		MaxSize: maxLogFileSize(cfg),
+		OnRotate: func(path string) {
+			logger.CleanupOldLogs(filepath.Dir(path), logger.CurrentCleanupConfig())
+		},
+	}
+	if err := logger.InitLogger(cfg.Log.ListenerDir, cfg.Log.EstablishedDir,
+		cfg.Log.Format, cfg.Log.ColorEnabled, cfg.Monitor.LogToConsole, rotate); err != nil {
+		panic(fmt.Sprintf("初始化日志失败: %v", err))
+	}
+
+	// 启动日志清理任务
+	logger.StartCleanupTask(cfg.Log.ListenerDir, cfg.Log.EstablishedDir, newCleanupConfig(cfg))
+
+	// 创建过滤器
+	filter, err := newFilter(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("过滤条件无效: %v", err))
+	}
+
+	// 打印启动信息
+	printStartupInfo(cfg, filter)
+
+	// 创建采集器
+	collector, err := newCollector(cfg, os.Stdout)
+	if err != nil {
+		panic(fmt.Sprintf("初始化采集器失败: %v", err))
+	}
+
+	// 采集初始快照,作为所有监控器的共同基线
+	snap, err := collector.Collect()
+	if err != nil {
+		panic(err)
+	}
+
+	// 初始化事件总线,日志、统计和Web界面作为订阅者各自消费事件
+	bus := event.NewBus()
+
+	// 初始化监控器
+	dispatcher := monitor.NewDispatcher(bus,
+		monitor.NewListenerMonitor(filter),
+		monitor.NewEstablishedMonitor(filter),
+		monitor.NewStateMonitor(filter, cfg.Monitor.CloseWaitThreshold))
+	dispatcher.TrackStates = cfg.Monitor.TrackStates
+	dispatcher.SetBaseline(snap)
+
+	bus.Consume(bus.Subscribe("logger", event.DefaultQueueSize), logger.HandleEvent)
+
+	if logger.Syslog != nil {
+		bus.Consume(bus.Subscribe("syslog", event.DefaultQueueSize), logger.Syslog.HandleEvent)
+	}
+
+	// 初始化历史库(如果启用)
+	var store *history.Store
+	if cfg.History.Enabled {
+		store, err = history.Open(cfg.History.Dir, history.Options{
+			SnapshotInterval: time.Duration(cfg.History.SnapshotInterval) * time.Second,
+			Retention:        time.Duration(cfg.History.RetentionDays) * 24 * time.Hour,
+		})
+		if err != nil {
+			panic(fmt.Sprintf("打开历史库失败: %v", err))
+		}
+		if err := store.RecordSnapshot(snap); err != nil {
+			logger.LogWarning(os.Stdout, fmt.Sprintf("保存历史快照失败: %v", err))
+		}
+		bus.Consume(bus.Subscribe("history", event.DefaultQueueSize), store.HandleEvent)
+	}
+
+	// 初始化统计
+	stats := monitor.NewStats()
+	stats.Update(snap)
+	bus.Consume(bus.Subscribe("stats", event.DefaultQueueSize), stats.HandleEvent)
+
+	// 启动定时检测
+	ticker := time.NewTicker(cfg.Monitor.GetInterval())
+	defer ticker.Stop()
+
+	d := &daemon{
+		cfgPath:    cfgPath,
+		cfg:        cfg,
+		dispatcher: dispatcher,
+		filter:     filter,
+		bus:        bus,
+		stats:      stats,
+		store:      store,
+		ticker:     ticker,
+		snap:       snap,
+	}
+
+	// 初始化Web服务器(如果启用)
+	if cfg.Web.Enabled {
+		d.startWeb()
+	}
+
+	// 设置优雅退出
+	setupExitHandler(bus, store)
+
+	// 收到SIGHUP或配置文件被修改时重新加载配置
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	cfgChanged := config.WatchFile(cfgPath, configWatchInterval)
+
+	// 统计显示定时器,是否显示由当前配置决定
+	statsTicker := time.NewTicker(10 * time.Second)
+	defer statsTicker.Stop()
+
+	for {
+		select {
+		case <-hup:
+			d.reload("SIGHUP")
+
+		case <-cfgChanged:
+			d.reload("配置文件已修改")
+
+		case <-ticker.C:
+			// 每轮只采集一次,监控器、统计和Web界面共享同一份快照
+			start := time.Now()
+			snap, err := collector.Collect()
+			if errors.Is(err, netinfo.ErrReplayExhausted) {
+				logger.LogInfo(os.Stdout, "快照回放结束")
+				bus.Close()
+				closeHistory(store)
+				logger.Close()
+				return 0
+			}
+			stats.RecordCollection(time.Since(start), err)
+			if err != nil {
+				logger.LogWarning(os.Stdout, fmt.Sprintf("连接信息采集错误: %v", err))
+				continue
+			}
+
+			d.snap = snap
+
+			// 检测变化并发布事件
+			dispatcher.Process(snap)
+
+			// 保存周期快照
+			if store != nil {
+				if err := store.RecordSnapshot(snap); err != nil {
+					logger.LogWarning(os.Stdout, fmt.Sprintf("保存历史快照失败: %v", err))
+				}
+			}
+
+			// 更新统计信息
+			stats.Update(snap)
+
+			// 更新Web服务器的连接列表
+			if d.web != nil {
+				d.web.UpdateConnections(snap)
+			}
+
+		case <-statsTicker.C:
+			if !d.cfg.Monitor.ShowStats {
+				continue
+			}
+			// 显示统计信息
+			logger.LogInfo(os.Stdout, stats.GetDisplay())
+			logDroppedEvents(bus)
+		}
+	}
+}
+
+// newCleanupConfig 日志清理配置
+func newCleanupConfig(cfg *config.Config) logger.CleanupConfig {
+	return logger.CleanupConfig{
+		Enabled:         true,
+		RetentionDays:   cfg.Log.RetentionDays,
+		CompressEnabled: cfg.Log.AutoCompress,
+		MaxTotalSize:    int64(cfg.Log.MaxTotalSize),
+	}
+}
+
+// maxLogFileSize 日志文件切分大小。
+// 只限制目录总大小时按上限的1/10切分,避免正在写入的文件无法被清理而超出上限
+func maxLogFileSize(cfg *config.Config) int64 {
+	if cfg.Log.MaxFileSize == 0 && cfg.Log.MaxTotalSize > 0 {
+		return int64(cfg.Log.MaxTotalSize) / 10
+	}
+	return int64(cfg.Log.MaxFileSize)
+}
+
+func printStartupInfo(cfg *config.Config, filter *netinfo.ConnectionFilter) {
+	fmt.Println("========================================")
+	fmt.Println("       网络连接监控器已启动")
+	fmt.Println("========================================")
+	fmt.Printf("检测间隔: %d 秒\n", cfg.Monitor.Interval)
+	if cfg.Monitor.ReplayFile != "" {
+		fmt.Printf("回放文件: %s\n", cfg.Monitor.ReplayFile)
+	} else {
+		fmt.Printf("采集后端: %s\n", getStringOrDefault(cfg.Monitor.Collector, netinfo.BackendGopsutil))
+	}
+	if cfg.Monitor.RecordFile != "" {
+		fmt.Printf("录制文件: %s\n", cfg.Monitor.RecordFile)
+	}
+	fmt.Printf("统计显示: %s\n", getBoolString(cfg.Monitor.ShowStats))
+	fmt.Printf("彩色输出: %s\n", getBoolString(cfg.Log.ColorEnabled))
+	fmt.Printf("日志格式: %s\n", getStringOrDefault(cfg.Log.Format, logger.FormatText))
+	fmt.Println("\n过滤配置:")
+	fmt.Printf("  进程: %s\n", getRulesString(filter.Processes, filter.ExcludeProcesses))
+	fmt.Printf("  PID过滤: %s\n", getPIDsString(filter.PIDs))
+	fmt.Printf("  协议类型: %s\n", getProtocolsString(filter.Protocols))
+	fmt.Printf("  地址族: %s\n", getProtocolsString(filter.Families))
+	fmt.Printf("  远程网段: %s\n", getRulesString(filter.RemoteNets, filter.ExcludeRemoteNets))
+	fmt.Printf("  本地网段: %s\n", getRulesString(filter.LocalNets, filter.ExcludeLocalNets))
+	fmt.Printf("  远程端口: %s\n", getRulesString(filter.RemotePorts, filter.ExcludeRemotePorts))
+	fmt.Printf("  本地端口: %s\n", getRulesString(filter.LocalPorts, filter.ExcludeLocalPorts))
+	fmt.Printf("  连接状态: %s\n", getProtocolsString(filter.States))
+	if filter.Expr != nil {
+		fmt.Printf("  过滤表达式: %s\n", filter.Expr)
+	}
+	fmt.Print("========================================\n\n")
+	logger.LogInfo(os.Stdout, "开始监控网络连接...")
+}
+
+func getBoolString(b bool) string {
+	if b {
+		return "启用"
+	}
+	return "禁用"
+}
+
+func getStringOrDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func getPIDsString(pids []int32) string {
+	if len(pids) == 0 {
+		return "全部"
+	}
+	result := ""
+	for i, pid := range pids {
+		if i > 0 {
+			result += ", "
+		}
+		result += fmt.Sprintf("%d", pid)
+	}
+	return result
+}
+
+func getProtocolsString(protocols []string) string {
+	if len(protocols) == 0 {
+		return "全部"
+	}
+	result := ""
+	for i, p := range protocols {
+		if i > 0 {
+			result += ", "
+		}
+		result += p
+	}
+	return result
+}
+
+// getRulesString 包含和排除的进程、网段或端口
+func getRulesString[T fmt.Stringer](include, exclude []T) string {
+	result := "全部"
+	if len(include) > 0 {
+		result = joinStrings(include)
+	}
+	if len(exclude) > 0 {
+		result += ",排除 " + joinStrings(exclude)
+	}
+	return result
+}
+
+func joinStrings[T fmt.Stringer](values []T) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = v.String()
+	}
+	return getProtocolsString(items)
+}
+
+// logDroppedEvents 提示因订阅者处理过慢而丢弃的事件
+func logDroppedEvents(bus *event.Bus) {
+	for _, st := range bus.Stats() {
+		if st.Dropped > 0 {
+			logger.LogWarning(os.Stdout, fmt.Sprintf("事件订阅者 %s 队列已满,累计丢弃 %d 个事件", st.Name, st.Dropped))
+		}
+	}
+}
+
+// closeHistory 关闭历史库,保存索引
+func closeHistory(store *history.Store) {
+	if store == nil {
+		return
+	}
+	if err := store.Close(); err != nil {
+		logger.LogWarning(os.Stdout, fmt.Sprintf("关闭历史库失败: %v", err))
+	}
+}
+
+func setupExitHandler(bus *event.Bus, store *history.Store) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigChan
+		fmt.Printf("\n收到信号 %v, 正在退出...\n", sig)
+		bus.Close() // 等待订阅者处理完已发布的事件
+		closeHistory(store)
+		logger.Close()
+		logger.LogInfo(os.Stdout, "监控器已停止")
+		os.Exit(0)
+	}()
+}
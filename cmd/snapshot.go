@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"netmonitor/pkg/monitor"
+	"netmonitor/pkg/netinfo"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// runSnapshot 采集一次连接并输出,JSON格式的输出可以保存下来供 diff 比较
+func runSnapshot(cfgPath string, args []string) int {
+	fs := newFlagSet("snapshot", "", &cfgPath)
+	format := fs.String("format", "table", "输出格式: table, json, csv")
+	output := fs.String("o", "", "输出文件(默认标准输出)")
+	listen := fs.Bool("listen", false, "只输出监听端口")
+	state := fs.String("state", "", "只输出指定状态的连接,例如 ESTABLISHED")
+	all := fs.Bool("all", false, "忽略 [filter] 配置")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		reportConfigError(cfgPath, err)
+		return 1
+	}
+	collector, err := newCollector(cfg, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化采集器失败: %v\n", err)
+		return 1
+	}
+	snap, err := collector.Collect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "采集连接失败: %v\n", err)
+		return 1
+	}
+
+	filter, err := newFilter(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "过滤条件无效: %v\n", err)
+		return 1
+	}
+	conns := make([]netinfo.Connection, 0, len(snap.Connections))
+	for _, c := range snap.Connections {
+		if !*all && filter.ShouldFilter(c) {
+			continue
+		}
+		if *listen && !monitor.IsListeningPort(c) {
+			continue
+		}
+		if *state != "" && !strings.EqualFold(c.Status, *state) {
+			continue
+		}
+		conns = append(conns, c)
+	}
+	sortConnections(conns)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "创建输出文件失败: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "table":
+		err = writeTable(w, conns)
+	case "json":
+		err = json.NewEncoder(w).Encode(netinfo.Snapshot{Timestamp: snap.Timestamp, Connections: conns})
+	case "csv":
+		err = writeCSV(w, conns)
+	default:
+		fmt.Fprintf(os.Stderr, "未知的输出格式: %s\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "输出失败: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// sortConnections 按协议、本地地址、远程地址排序,保证输出稳定
+func sortConnections(conns []netinfo.Connection) {
+	sort.Slice(conns, func(i, j int) bool {
+		a, b := conns[i], conns[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if c := a.LocalAddr.Compare(b.LocalAddr); c != 0 {
+			return c < 0
+		}
+		return a.RemoteAddr.Compare(b.RemoteAddr) < 0
+	})
+}
+
+// peerAddr 远程地址,未连接时与 ss 一样显示为 *:*
+func peerAddr(c netinfo.Connection) string {
+	if !c.RemoteAddr.IsValid() || c.RemoteAddr.Addr().IsUnspecified() && c.RemoteAddr.Port() == 0 {
+		return "*:*"
+	}
+	return netinfo.FormatAddr(c.RemoteAddr)
+}
+
+// processColumn PID/进程名,与 netstat -p 一致
+func processColumn(c netinfo.Connection) string {
+	if c.PID <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%s", c.PID, c.ProcessName)
+}
+
+// writeTable 以类似 ss 的表格输出连接
+func writeTable(w io.Writer, conns []netinfo.Connection) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Netid\tState\tRecv-Q\tSend-Q\tLocal Address:Port\tPeer Address:Port\tPID/Program")
+	for _, c := range conns {
+		state := c.Status
+		if state == "" || state == "NONE" {
+			state = "UNCONN"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			strings.ToLower(c.Protocol), state, c.RxQueue, c.TxQueue,
+			netinfo.FormatAddr(c.LocalAddr), peerAddr(c), processColumn(c))
+	}
+	return tw.Flush()
+}
+
+// writeCSV 以CSV输出连接,地址拆分为IP和端口两列
+func writeCSV(w io.Writer, conns []netinfo.Connection) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"protocol", "family", "state", "local_ip", "local_port", "remote_ip", "remote_port",
+		"pid", "process_name", "uid", "inode", "rx_queue", "tx_queue"})
+	for _, c := range conns {
+		var remoteIP, remotePort string
+		if c.RemoteAddr.IsValid() {
+			remoteIP = c.RemoteAddr.Addr().String()
+			remotePort = strconv.Itoa(int(c.RemoteAddr.Port()))
+		}
+		cw.Write([]string{
+			c.Protocol, c.Family, c.Status,
+			c.LocalAddr.Addr().String(), strconv.Itoa(int(c.LocalAddr.Port())),
+			remoteIP, remotePort,
+			strconv.Itoa(int(c.PID)), c.ProcessName,
+			strconv.FormatUint(uint64(c.UID), 10), strconv.FormatUint(c.Inode, 10),
+			strconv.FormatUint(uint64(c.RxQueue), 10), strconv.FormatUint(uint64(c.TxQueue), 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
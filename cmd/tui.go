@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/monitor"
+	"netmonitor/pkg/tui"
+	"os"
+	"time"
+)
+
+// runTUI 全屏终端界面,适合通过SSH使用;与 watch 一样不写日志文件
+func runTUI(cfgPath string, args []string) int {
+	fs := newFlagSet("tui", "", &cfgPath)
+	interval := fs.Int("interval", 0, "检测间隔秒数(0表示使用配置)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		reportConfigError(cfgPath, err)
+		return 1
+	}
+
+	filter, err := newFilter(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "过滤条件无效: %v\n", err)
+		return 1
+	}
+	collector, err := newCollector(cfg, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化采集器失败: %v\n", err)
+		return 1
+	}
+	snap, err := collector.Collect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "采集连接失败: %v\n", err)
+		return 1
+	}
+
+	bus := event.NewBus()
+	dispatcher := monitor.NewDispatcher(bus,
+		monitor.NewListenerMonitor(filter),
+		monitor.NewEstablishedMonitor(filter),
+		monitor.NewStateMonitor(filter, cfg.Monitor.CloseWaitThreshold))
+	dispatcher.TrackStates = cfg.Monitor.TrackStates
+	dispatcher.SetBaseline(snap)
+
+	stats := monitor.NewStats()
+	stats.Update(snap)
+	bus.Consume(bus.Subscribe("stats", event.DefaultQueueSize), stats.HandleEvent)
+
+	period := cfg.Monitor.GetInterval()
+	if *interval > 0 {
+		period = time.Duration(*interval) * time.Second
+	}
+	app := tui.New(tui.Options{
+		Collector:  collector,
+		Dispatcher: dispatcher,
+		Filter:     filter,
+		Stats:      stats,
+		Interval:   period,
+	})
+	bus.Consume(bus.Subscribe("tui", event.DefaultQueueSize), app.HandleEvent)
+
+	err = app.Run(snap)
+	bus.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
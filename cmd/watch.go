@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/logger"
+	"netmonitor/pkg/monitor"
+	"netmonitor/pkg/netinfo"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runWatch 与 run 使用相同的采集器和监控器,但只把事件输出到标准输出,不写日志文件
+func runWatch(cfgPath string, args []string) int {
+	fs := newFlagSet("watch", "", &cfgPath)
+	format := fs.String("format", "text", "输出格式: text, json(每行一个事件记录)")
+	interval := fs.Int("interval", 0, "检测间隔秒数(0表示使用配置)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		reportConfigError(cfgPath, err)
+		return 1
+	}
+
+	var handler func(event.Event)
+	switch *format {
+	case "text":
+		logger.InitConsoleLogger(cfg.Log.ColorEnabled)
+		handler = logger.HandleEvent
+	case "json":
+		host, _ := os.Hostname()
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		handler = func(e event.Event) {
+			enc.Encode(logger.NewRecord(e, host))
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "未知的输出格式: %s\n", *format)
+		return 2
+	}
+
+	filter, err := newFilter(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "过滤条件无效: %v\n", err)
+		return 1
+	}
+	collector, err := newCollector(cfg, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化采集器失败: %v\n", err)
+		return 1
+	}
+	snap, err := collector.Collect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "采集连接失败: %v\n", err)
+		return 1
+	}
+
+	bus := event.NewBus()
+	dispatcher := monitor.NewDispatcher(bus,
+		monitor.NewListenerMonitor(filter),
+		monitor.NewEstablishedMonitor(filter),
+		monitor.NewStateMonitor(filter, cfg.Monitor.CloseWaitThreshold))
+	dispatcher.TrackStates = cfg.Monitor.TrackStates
+	dispatcher.SetBaseline(snap)
+	bus.Consume(bus.Subscribe("stdout", event.DefaultQueueSize), handler)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	period := cfg.Monitor.GetInterval()
+	if *interval > 0 {
+		period = time.Duration(*interval) * time.Second
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sigChan:
+			bus.Close() // 输出已发布的事件后退出
+			return 0
+		case <-ticker.C:
+			snap, err := collector.Collect()
+			if errors.Is(err, netinfo.ErrReplayExhausted) {
+				bus.Close()
+				return 0
+			}
+			if err != nil {
+				logger.LogWarning(os.Stderr, fmt.Sprintf("连接信息采集错误: %v", err))
+				continue
+			}
+			dispatcher.Process(snap)
+		}
+	}
+}
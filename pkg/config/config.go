@@ -1,6 +1,8 @@
 package config
 
 import (
+	"bufio"
+	"netmonitor/pkg/netinfo"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,27 +16,58 @@ type Config struct {
 	Monitor MonitorConfig
 	Filter  FilterConfig
 	Web     WebConfig
+	Syslog  SyslogConfig
+	History HistoryConfig
+
+	lines     map[string]int    // 配置项在配置文件中的行号,用于报告问题
+	undecoded []string          // 配置文件中无法识别的配置项
+	sources   map[string]string // 配置项取值的来源,未记录的为默认值
 }
 
 type LogConfig struct {
-	ListenerDir     string `toml:"listener_dir"`
-	EstablishedDir  string `toml:"established_dir"`
-	ColorEnabled    bool   `toml:"color_enabled"`
-	RetentionDays   int    `toml:"retention_days"`    // 日志保留天数
-	AutoCompress    bool   `toml:"auto_compress"`    // 是否自动压缩日志
+	ListenerDir    string   `toml:"listener_dir"`
+	EstablishedDir string   `toml:"established_dir"`
+	ColorEnabled   bool     `toml:"color_enabled"`
+	Format         string   `toml:"format"`         // 日志文件格式: text, json(每行一个JSON对象)
+	RetentionDays  int      `toml:"retention_days"` // 日志保留天数
+	AutoCompress   bool     `toml:"auto_compress"`  // 是否自动压缩日志
+	MaxFileSize    ByteSize `toml:"max_file_size"`  // 单个日志文件超过该大小时切分为编号分段,0表示只在每天零点切分
+	MaxTotalSize   ByteSize `toml:"max_total_size"` // 每个日志目录的总大小上限,超出时从最旧的日志开始删除,0表示不限制
 }
 
 type MonitorConfig struct {
-	Interval      int  `toml:"interval"`
-	ShowStats     bool `toml:"show_stats"`
-	LogToConsole  bool `toml:"log_to_console"`
+	Interval           int      `toml:"interval"`
+	ShowStats          bool     `toml:"show_stats"`
+	LogToConsole       bool     `toml:"log_to_console"`
+	Collector          string   `toml:"collector"`            // 采集后端: gopsutil, procfs, netlink(后两者仅Linux)
+	RecordFile         string   `toml:"record_file"`          // 录制快照到文件(留空表示不录制)
+	ReplayFile         string   `toml:"replay_file"`          // 从录制文件回放快照,代替实时采集
+	NetlinkStates      []string `toml:"netlink_states"`       // netlink后端在内核侧保留的TCP状态(留空表示全部)
+	TrackStates        bool     `toml:"track_states"`         // 是否记录TCP状态变化
+	CloseWaitThreshold int      `toml:"close_wait_threshold"` // 单进程CLOSE_WAIT连接数告警阈值(0表示不告警)
 }
 
 type FilterConfig struct {
-	ProcessName string   `toml:"process_name"` // 进程名称过滤(留空表示不过滤)
-	PIDs         []int32 `toml:"pids"`         // PID过滤(留空表示不过滤)
-	Protocols    []string `toml:"protocols"`   // 协议过滤: tcp, udp
-	RemoteIP     string   `toml:"remote_ip"`   // 远程IP过滤(留空表示不过滤)
+	ProcessName      string   `toml:"process_name"`      // 进程名称过滤(留空表示不过滤),与 processes 合并
+	Processes        []string `toml:"processes"`         // 进程匹配规则: 名称、通配符、re:正则,可加 exe:/cmdline: 前缀(留空表示不过滤)
+	ExcludeProcesses []string `toml:"exclude_processes"` // 排除的进程,规则同 processes,优先于所有包含条件
+	PIDs             []int32  `toml:"pids"`              // PID过滤(留空表示不过滤)
+	Protocols        []string `toml:"protocols"`         // 协议过滤: tcp, udp
+	Families         []string `toml:"families"`          // 地址族过滤: ipv4, ipv6(留空表示不过滤)
+	RemoteIP         string   `toml:"remote_ip"`         // 远程IP或网段(留空表示不过滤),与 remote_nets 合并
+	States           []string `toml:"states"`            // 连接状态过滤,例如 LISTEN, ESTABLISHED(留空表示不过滤)
+	Expr             string   `toml:"expr"`              // 过滤表达式,例如 proto == tcp and dport in 443,8443(留空表示不过滤)
+
+	// 地址和端口过滤: 网段为IP或CIDR,端口为整数或 "8000-8100" 形式的范围;
+	// 包含列表留空表示不过滤,匹配任一排除列表的连接总是被过滤
+	RemoteNets         []string            `toml:"remote_nets"`
+	ExcludeRemoteNets  []string            `toml:"exclude_remote_nets"`
+	LocalNets          []string            `toml:"local_nets"`
+	ExcludeLocalNets   []string            `toml:"exclude_local_nets"`
+	RemotePorts        []netinfo.PortRange `toml:"remote_ports"`
+	ExcludeRemotePorts []netinfo.PortRange `toml:"exclude_remote_ports"`
+	LocalPorts         []netinfo.PortRange `toml:"local_ports"`
+	ExcludeLocalPorts  []netinfo.PortRange `toml:"exclude_local_ports"`
 }
 
 type WebConfig struct {
@@ -42,38 +75,105 @@ type WebConfig struct {
 	Port    int  `toml:"port"`    // Web服务端口
 }
 
-func LoadConfig(path string) (*Config, error) {
-	cfg := &Config{
+type SyslogConfig struct {
+	Enabled  bool   `toml:"enabled"`  // 是否发送事件到syslog
+	Network  string `toml:"network"`  // 传输方式: unixgram(本机), udp, tcp
+	Address  string `toml:"address"`  // 留空时: unixgram为/dev/log, udp/tcp为localhost:514
+	Facility string `toml:"facility"` // syslog facility,例如 daemon, local0
+	Tag      string `toml:"tag"`      // APP-NAME
+}
+
+type HistoryConfig struct {
+	Enabled          bool   `toml:"enabled"`           // 是否保存连接历史
+	Dir              string `toml:"dir"`               // 历史库目录
+	SnapshotInterval int    `toml:"snapshot_interval"` // 周期快照间隔(秒),0表示不保存快照
+	RetentionDays    int    `toml:"retention_days"`    // 历史保留天数,0表示永久保留
+}
+
+// Default 内置的默认配置
+func Default() *Config {
+	return &Config{
 		Log: LogConfig{
-			ListenerDir:     "logs/listener_logs",
-			EstablishedDir:  "logs/established_logs",
-			ColorEnabled:     true,
-			RetentionDays:   7,
-			AutoCompress:    true,
+			ListenerDir:    "logs/listener_logs",
+			EstablishedDir: "logs/established_logs",
+			ColorEnabled:   true,
+			Format:         "text",
+			RetentionDays:  7,
+			AutoCompress:   true,
+			MaxFileSize:    0,
+			MaxTotalSize:   0,
 		},
 		Monitor: MonitorConfig{
-			Interval:      1,
-			ShowStats:     true,
-			LogToConsole:  true,
+			Interval:           1,
+			ShowStats:          true,
+			LogToConsole:       true,
+			Collector:          "gopsutil",
+			NetlinkStates:      []string{},
+			TrackStates:        false,
+			CloseWaitThreshold: 20,
 		},
 		Filter: FilterConfig{
-			ProcessName: "",
-			PIDs:        []int32{},
-			Protocols:   []string{"tcp", "udp"},
-			RemoteIP:    "",
+			ProcessName:      "",
+			Processes:        []string{},
+			ExcludeProcesses: []string{},
+			PIDs:             []int32{},
+			Protocols:        []string{"tcp", "udp"},
+			Families:         []string{},
+			RemoteIP:         "",
+			States:           []string{},
+			Expr:             "",
+
+			RemoteNets:         []string{},
+			ExcludeRemoteNets:  []string{},
+			LocalNets:          []string{},
+			ExcludeLocalNets:   []string{},
+			RemotePorts:        []netinfo.PortRange{},
+			ExcludeRemotePorts: []netinfo.PortRange{},
+			LocalPorts:         []netinfo.PortRange{},
+			ExcludeLocalPorts:  []netinfo.PortRange{},
 		},
 		Web: WebConfig{
 			Enabled: false,
 			Port:    8080,
 		},
+		Syslog: SyslogConfig{
+			Enabled:  false,
+			Network:  "unixgram",
+			Facility: "daemon",
+			Tag:      "netmonitor",
+		},
+		History: HistoryConfig{
+			Enabled:          false,
+			Dir:              "data/history",
+			SnapshotInterval: 300,
+			RetentionDays:    30,
+		},
 	}
+}
 
-	if _, err := toml.DecodeFile(path, cfg); err != nil {
+// LoadConfig 在默认配置上加载配置文件,文件不存在时返回默认配置
+func LoadConfig(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if err != nil {
 		if os.IsNotExist(err) {
 			return cfg, nil // 返回默认配置
 		}
 		return nil, err
 	}
+	md, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.lines = keyLines(data)
+	for _, key := range knownKeys() {
+		if md.IsDefined(strings.Split(key, ".")...) {
+			cfg.setSource(key, SourceFile)
+		}
+	}
+	for _, key := range md.Undecoded() {
+		cfg.undecoded = append(cfg.undecoded, key.String())
+	}
 	return cfg, nil
 }
 
@@ -82,81 +182,20 @@ func (m *MonitorConfig) GetInterval() time.Duration {
 	return time.Duration(m.Interval) * time.Second
 }
 
-// 检查协议是否在过滤列表中
-func (f *FilterConfig) ShouldFilterProtocol(protocol string) bool {
-	if len(f.Protocols) == 0 {
-		return false // 不过滤
-	}
-	for _, p := range f.Protocols {
-		if strings.EqualFold(p, protocol) {
-			return false // 匹配,不过滤
-		}
-	}
-	return true // 不匹配,过滤
-}
-
-// 检查进程是否应该被过滤
-func (f *FilterConfig) ShouldFilterProcess(pid int32, processName string) bool {
-	// 检查进程名
-	if f.ProcessName != "" {
-		if !strings.EqualFold(processName, f.ProcessName) {
-			return true // 过滤
-		}
+// InitConfig 以内置默认值创建配置文件,内容与 Print 的输出一致;文件已存在时不覆盖并返回错误
+func InitConfig(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
-
-	// 检查PID列表
-	if len(f.PIDs) > 0 {
-		found := false
-		for _, p := range f.PIDs {
-			if p == pid {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return true // 过滤
-		}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
 	}
-
-	// 检查远程IP(需要在连接信息中检查)
-	return false // 不过滤
-}
-
-// 检查远程地址是否应该被过滤
-func (f *FilterConfig) ShouldFilterRemoteAddr(remoteAddr string) bool {
-	if f.RemoteIP == "" {
-		return false
+	w := bufio.NewWriter(f)
+	Default().Print(w, false)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
 	}
-	return !strings.Contains(remoteAddr, f.RemoteIP)
+	return f.Close()
 }
-
-// 初始化配置文件（如果不存在则创建）
-func InitConfig(path string) error {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		dir := filepath.Dir(path)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return err
-		}
-
-		defaultCfg := `[log]
-listener_dir = "logs/listener_logs"
-established_dir = "logs/established_logs"
-color_enabled = true
-
-[monitor]
-interval = 1  # 单位：秒
-show_stats = true
-log_to_console = true
-
-[filter]
-# 留空表示不过滤
-process_name = ""  # 要监控的进程名称,例如 "chrome.exe"
-pids = []          # 要监控的PID列表,例如 [1234, 5678]
-protocols = ["tcp", "udp"]  # 监控的协议类型
-remote_ip = ""      # 过滤特定远程IP
-`
-
-		return os.WriteFile(path, []byte(defaultCfg), 0644)
-	}
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Change 两份配置之间一个配置项的变化
+type Change struct {
+	Key string // 配置项路径,例如 filter.process_name
+	Old string
+	New string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// Diff 按配置项比较两份配置,返回发生变化的配置项
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		section := tomlKey(t.Field(i))
+		oldSection, newSection := ov.Field(i), nv.Field(i)
+		st := oldSection.Type()
+		for j := 0; j < st.NumField(); j++ {
+			o, n := oldSection.Field(j).Interface(), newSection.Field(j).Interface()
+			if reflect.DeepEqual(o, n) || isEmptyList(o) && isEmptyList(n) {
+				continue
+			}
+			changes = append(changes, Change{
+				Key: section + "." + tomlKey(st.Field(j)),
+				Old: formatValue(o),
+				New: formatValue(n),
+			})
+		}
+	}
+	return changes
+}
+
+// tomlKey 字段对应的TOML键名,未设置标签时与解析时一样使用小写的字段名
+func tomlKey(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("toml"), ","); name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+// isEmptyList nil 与空切片视为相同
+func isEmptyList(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Slice && rv.Len() == 0
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []string:
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 配置项取值的来源,优先级从低到高
+const (
+	SourceDefault = "default" // 内置默认值
+	SourceFile    = "file"    // 配置文件
+	SourceEnv     = "env"     // NETMONITOR_* 环境变量
+	SourceFlag    = "flag"    // 命令行选项
+)
+
+// EnvPrefix 覆盖配置项的环境变量前缀,例如 NETMONITOR_MONITOR_INTERVAL 对应 monitor.interval
+const EnvPrefix = "NETMONITOR_"
+
+// EnvName 配置项对应的环境变量名
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Load 按 默认值 < 配置文件 < 环境变量 < 命令行选项 的顺序加载配置
+func Load(path string, overrides Overrides) (*Config, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Source 配置项当前取值的来源
+func (c *Config) Source(key string) string {
+	if s, ok := c.sources[key]; ok {
+		return s
+	}
+	return SourceDefault
+}
+
+// Set 以字符串设置配置项并记录来源。列表以逗号分隔,大小可以带单位
+func (c *Config) Set(key, value, source string) error {
+	v, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("未知的配置项: %s", key)
+	}
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	c.setSource(key, source)
+	return nil
+}
+
+// ApplyEnv 用 NETMONITOR_* 环境变量覆盖配置项,lookup 通常为 os.LookupEnv
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	for _, key := range knownKeys() {
+		name := EnvName(key)
+		if value, ok := lookup(name); ok {
+			if err := c.Set(key, value, SourceEnv); err != nil {
+				return fmt.Errorf("环境变量 %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) setSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// field 配置项对应的字段
+func (c *Config) field(key string) (reflect.Value, bool) {
+	section, name, ok := strings.Cut(key, ".")
+	if !ok {
+		return reflect.Value{}, false
+	}
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() || tomlKey(t.Field(i)) != section {
+			continue
+		}
+		sv := v.Field(i)
+		for j := 0; j < sv.NumField(); j++ {
+			if tomlKey(sv.Type().Field(j)) == name {
+				return sv.Field(j), true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setValue 解析字符串并写入字段
+func setValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("无效的布尔值: %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("无效的整数: %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Slice:
+		items := splitList(s)
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(list.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(list)
+	default:
+		return fmt.Errorf("不支持的类型: %s", v.Type())
+	}
+	return nil
+}
+
+// splitList 拆分逗号分隔的列表,空字符串表示空列表
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Overrides 命令行中指定的配置项,按出现的顺序应用
+type Overrides []Override
+
+// Override 一个命令行配置项
+type Override struct {
+	Key   string
+	Value string
+}
+
+// Register 为每个配置项注册一个 -段.键 选项,例如 -monitor.interval 5、-web.enabled
+func (o *Overrides) Register(fs *flag.FlagSet) {
+	for _, key := range knownKeys() {
+		key := key
+		v, _ := (&Config{}).field(key)
+		usage := fmt.Sprintf("覆盖配置项 %s (环境变量 %s)", key, EnvName(key))
+		set := func(value string) error {
+			// 先在空配置上解析一次,尽早报告无效的取值
+			if err := (&Config{}).Set(key, value, SourceFlag); err != nil {
+				return err
+			}
+			*o = append(*o, Override{Key: key, Value: value})
+			return nil
+		}
+		if v.Kind() == reflect.Bool {
+			fs.BoolFunc(key, usage, set)
+		} else {
+			fs.Func(key, usage, set)
+		}
+	}
+}
+
+// IsOverrideFlag 选项名是否为 Register 注册的配置项选项
+func IsOverrideFlag(name string) bool {
+	return strings.Contains(name, ".")
+}
+
+// Apply 将命令行配置项应用到配置
+func (o Overrides) Apply(c *Config) error {
+	for _, ov := range o {
+		if err := c.Set(ov.Key, ov.Value, SourceFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Print 以TOML格式输出配置,withSource 为 true 时在每项后注明取值来源
+func (c *Config) Print(w io.Writer, withSource bool) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		section := tomlKey(t.Field(i))
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "[%s]\n", section)
+
+		sv := v.Field(i)
+		for j := 0; j < sv.NumField(); j++ {
+			name := tomlKey(sv.Type().Field(j))
+			line := fmt.Sprintf("%s = %s", name, tomlValue(sv.Field(j)))
+			if withSource {
+				line = fmt.Sprintf("%-40s # %s", line, c.describeSource(section+"."+name))
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// describeSource 取值来源的说明,环境变量和命令行选项给出具体名称
+func (c *Config) describeSource(key string) string {
+	switch s := c.Source(key); s {
+	case SourceEnv:
+		return s + " " + EnvName(key)
+	case SourceFlag:
+		return s + " -" + key
+	default:
+		return s
+	}
+}
+
+// tomlValue 字段值的TOML表示
+func tomlValue(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, _ := m.MarshalText()
+		return strconv.Quote(string(text))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = tomlValue(v.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(v.Interface())
+}
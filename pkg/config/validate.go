@@ -0,0 +1,339 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"netmonitor/pkg/netinfo"
+	"reflect"
+	"strings"
+)
+
+// Problem 配置中的一个问题
+type Problem struct {
+	Key     string // 配置项路径,例如 monitor.interval
+	Line    int    // 在配置文件中的行号,0表示取值不是来自配置文件
+	Origin  string // 取值来自环境变量或命令行选项时为其名称,例如 "环境变量 NETMONITOR_MONITOR_INTERVAL"
+	Message string
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.Line > 0:
+		return fmt.Sprintf("第%d行 %s: %s", p.Line, p.Key, p.Message)
+	case p.Origin != "":
+		return fmt.Sprintf("%s %s: %s", p.Origin, p.Key, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Key, p.Message)
+}
+
+// problem 配置项的问题,按取值的来源给出行号或环境变量、命令行选项的名称
+func (c *Config) problem(key, msg string) Problem {
+	p := Problem{Key: key, Message: msg}
+	switch c.Source(key) {
+	case SourceFile:
+		p.Line = c.lines[key]
+	case SourceEnv:
+		p.Origin = "环境变量 " + EnvName(key)
+	case SourceFlag:
+		p.Origin = "选项 -" + key
+	}
+	return p
+}
+
+// ValidationError Validate 发现的全部问题
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// 与 netinfo 中的TCP状态名称一致,UDP套接字的状态为 NONE
+var tcpStates = []string{
+	"ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT",
+	"CLOSE", "CLOSE_WAIT", "LAST_ACK", "LISTEN", "CLOSING",
+}
+
+// 与 logger 中支持的syslog facility一致
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Validate 检查配置取值是否有效,发现问题时返回包含全部问题的 *ValidationError
+func (c *Config) Validate() error {
+	var problems []Problem
+	add := func(key, format string, args ...interface{}) {
+		problems = append(problems, c.problem(key, fmt.Sprintf(format, args...)))
+	}
+
+	if !oneOf(c.Log.Format, "", "text", "json") {
+		add("log.format", "只能是 text 或 json,当前为 %q", c.Log.Format)
+	}
+	if c.Log.RetentionDays < 0 {
+		add("log.retention_days", "不能为负数,当前为 %d", c.Log.RetentionDays)
+	}
+	if c.Log.MaxFileSize < 0 {
+		add("log.max_file_size", "不能为负数,当前为 %d", c.Log.MaxFileSize)
+	}
+	if c.Log.MaxTotalSize < 0 {
+		add("log.max_total_size", "不能为负数,当前为 %d", c.Log.MaxTotalSize)
+	}
+
+	if c.Monitor.Interval <= 0 {
+		add("monitor.interval", "必须大于0,当前为 %d", c.Monitor.Interval)
+	}
+	if !oneOf(c.Monitor.Collector, "", "gopsutil", "procfs", "netlink") {
+		add("monitor.collector", "只能是 gopsutil, procfs 或 netlink,当前为 %q", c.Monitor.Collector)
+	}
+	for _, s := range c.Monitor.NetlinkStates {
+		if !oneOf(s, tcpStates...) {
+			add("monitor.netlink_states", "未知的TCP状态 %q", s)
+		}
+	}
+	if c.Monitor.CloseWaitThreshold < 0 {
+		add("monitor.close_wait_threshold", "不能为负数,当前为 %d", c.Monitor.CloseWaitThreshold)
+	}
+	if c.Monitor.RecordFile != "" && c.Monitor.RecordFile == c.Monitor.ReplayFile {
+		add("monitor.record_file", "不能与 replay_file 相同")
+	}
+
+	if c.Filter.ProcessName != "" {
+		if _, err := netinfo.ParseProcessMatcher(c.Filter.ProcessName); err != nil {
+			add("filter.process_name", "%v", err)
+		}
+	}
+	for _, m := range []struct {
+		key  string
+		list []string
+	}{
+		{"filter.processes", c.Filter.Processes},
+		{"filter.exclude_processes", c.Filter.ExcludeProcesses},
+	} {
+		for _, s := range m.list {
+			if _, err := netinfo.ParseProcessMatcher(s); err != nil {
+				add(m.key, "%v", err)
+			}
+		}
+	}
+	for _, pid := range c.Filter.PIDs {
+		if pid <= 0 {
+			add("filter.pids", "PID必须大于0,当前为 %d", pid)
+		}
+	}
+	for _, p := range c.Filter.Protocols {
+		if !oneOf(p, "tcp", "udp") {
+			add("filter.protocols", "只能包含 tcp, udp,当前为 %q", p)
+		}
+	}
+	for _, f := range c.Filter.Families {
+		if !oneOf(f, "ipv4", "ipv6") {
+			add("filter.families", "只能包含 ipv4, ipv6,当前为 %q", f)
+		}
+	}
+	for _, s := range c.Filter.States {
+		if !oneOf(s, append(tcpStates, "NONE")...) {
+			add("filter.states", "未知的连接状态 %q", s)
+		}
+	}
+	if c.Filter.Expr != "" {
+		if _, err := netinfo.ParseFilterExpr(c.Filter.Expr); err != nil {
+			add("filter.expr", "%v", err)
+		}
+	}
+	if c.Filter.RemoteIP != "" {
+		if _, err := netinfo.ParsePrefix(c.Filter.RemoteIP); err != nil {
+			add("filter.remote_ip", "%v", err)
+		}
+	}
+	for _, n := range []struct {
+		key  string
+		list []string
+	}{
+		{"filter.remote_nets", c.Filter.RemoteNets},
+		{"filter.exclude_remote_nets", c.Filter.ExcludeRemoteNets},
+		{"filter.local_nets", c.Filter.LocalNets},
+		{"filter.exclude_local_nets", c.Filter.ExcludeLocalNets},
+	} {
+		for _, s := range n.list {
+			if _, err := netinfo.ParsePrefix(s); err != nil {
+				add(n.key, "%v", err)
+			}
+		}
+	}
+
+	if c.Web.Port <= 0 || c.Web.Port > 65535 {
+		add("web.port", "超出范围(1-65535),当前为 %d", c.Web.Port)
+	}
+
+	if !oneOf(c.Syslog.Network, "", "unixgram", "unix", "udp", "tcp") {
+		add("syslog.network", "只能是 unixgram, udp 或 tcp,当前为 %q", c.Syslog.Network)
+	}
+	if !oneOf(c.Syslog.Facility, append(syslogFacilities, "")...) {
+		add("syslog.facility", "未知的facility %q", c.Syslog.Facility)
+	}
+	if c.Syslog.Address != "" && oneOf(c.Syslog.Network, "udp", "tcp") {
+		if _, _, err := net.SplitHostPort(c.Syslog.Address); err != nil {
+			add("syslog.address", "udp/tcp地址的格式应为 主机:端口,当前为 %q", c.Syslog.Address)
+		}
+	}
+
+	if c.History.Enabled && c.History.Dir == "" {
+		add("history.dir", "启用历史库时不能为空")
+	}
+	if c.History.SnapshotInterval < 0 {
+		add("history.snapshot_interval", "不能为负数,当前为 %d", c.History.SnapshotInterval)
+	}
+	if c.History.RetentionDays < 0 {
+		add("history.retention_days", "不能为负数,当前为 %d", c.History.RetentionDays)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Warnings 不影响启动但可能不符合预期的配置: 配置文件中无法识别的配置项(通常是拼写错误,不会生效),
+// 以及netlink后端在内核侧丢弃了监控器需要的TCP状态
+func (c *Config) Warnings() []Problem {
+	var warnings []Problem
+	for _, key := range c.undecoded {
+		// 未知配置段只报告一次,不再逐项报告其中的键
+		if parent, _, ok := strings.Cut(key, "."); ok && contains(c.undecoded, parent) {
+			continue
+		}
+		msg := "未知的配置项,已忽略"
+		if s := suggestKey(key); s != "" {
+			msg += fmt.Sprintf(",是否为 %s?", s)
+		}
+		warnings = append(warnings, Problem{Key: key, Line: c.lines[key], Message: msg})
+	}
+	return append(warnings, c.netlinkStateWarnings()...)
+}
+
+// netlinkStateWarnings netlink_states 未包含监控器依赖的TCP状态时,对应的监控不会看到任何连接
+func (c *Config) netlinkStateWarnings() []Problem {
+	states := c.Monitor.NetlinkStates
+	if !strings.EqualFold(c.Monitor.Collector, "netlink") || len(states) == 0 {
+		return nil
+	}
+	var warnings []Problem
+	for _, dep := range []struct {
+		state  string
+		needed bool
+		effect string
+	}{
+		{"LISTEN", true, "监听端口监控将看不到TCP监听端口"},
+		{"ESTABLISHED", true, "已建立连接监控将看不到任何TCP连接"},
+		{"CLOSE_WAIT", c.Monitor.CloseWaitThreshold > 0, "close_wait_threshold 告警不会触发"},
+	} {
+		if dep.needed && !oneOf(dep.state, states...) {
+			msg := fmt.Sprintf("未包含 %s,%s", dep.state, dep.effect)
+			warnings = append(warnings, c.problem("monitor.netlink_states", msg))
+		}
+	}
+	return warnings
+}
+
+// oneOf 不区分大小写地判断 s 是否为候选值之一
+func oneOf(s string, candidates ...string) bool {
+	for _, c := range candidates {
+		if strings.EqualFold(s, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// keyLines 扫描配置文件,记录每个配置段和配置项第一次出现的行号
+func keyLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	section := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			name, _, _ := strings.Cut(strings.TrimLeft(line, "["), "]")
+			section = strings.TrimSpace(name)
+			if _, ok := lines[section]; !ok {
+				lines[section] = i + 1
+			}
+			continue
+		}
+		key, _, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, ` "',#[]{}`) {
+			continue
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, ok := lines[key]; !ok {
+			lines[key] = i + 1
+		}
+	}
+	return lines
+}
+
+// knownKeys 所有配置项的路径
+func knownKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		section := tomlKey(t.Field(i))
+		st := t.Field(i).Type
+		for j := 0; j < st.NumField(); j++ {
+			keys = append(keys, section+"."+tomlKey(st.Field(j)))
+		}
+	}
+	return keys
+}
+
+// suggestKey 与未知配置项最接近的已知配置项,编辑距离超过2时不给出建议
+func suggestKey(key string) string {
+	best, bestDist := "", 3
+	for _, k := range knownKeys() {
+		if d := editDistance(key, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance 两个字符串之间的编辑距离(Levenshtein)
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// WatchFile 每隔 interval 检查一次文件的修改时间和大小,发生变化时向返回的通道发送通知。
+// 文件被编辑器替换或暂时删除时同样视为变化,未处理的通知会被合并
+func WatchFile(path string, interval time.Duration) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	go func() {
+		last := fileStamp(path)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			stamp := fileStamp(path)
+			if stamp == last {
+				continue
+			}
+			last = stamp
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changed
+}
+
+// stamp 用于判断文件是否变化的信息,文件不存在时为零值
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func fileStamp(path string) stamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return stamp{}
+	}
+	return stamp{modTime: info.ModTime(), size: info.Size()}
+}
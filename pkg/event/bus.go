@@ -0,0 +1,149 @@
+package event
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultQueueSize 订阅者默认队列长度
+const DefaultQueueSize = 1024
+
+// Bus 进程内事件总线。
+// 每个订阅者拥有独立的有界队列,队列满时丢弃事件并计数,发布方永远不会被慢速订阅者阻塞
+type Bus struct {
+	subs   []*Subscription
+	mu     sync.RWMutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// Subscription 总线上的一个订阅者
+type Subscription struct {
+	name      string
+	ch        chan Event
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// SubscriberStats 订阅者的投递统计
+type SubscriberStats struct {
+	Name      string `json:"name"`
+	Queued    int    `json:"queued"`
+	Capacity  int    `json:"capacity"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe 注册订阅者,size 为队列长度(<=0 时使用 DefaultQueueSize)
+func (b *Bus) Subscribe(name string, size int) *Subscription {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	sub := &Subscription{
+		name: name,
+		ch:   make(chan Event, size),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.ch)
+		return sub
+	}
+	b.subs = append(b.subs, sub)
+	return sub
+}
+
+// Unsubscribe 注销订阅者并关闭其队列
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, s := range b.subs {
+		if s == sub {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+// Publish 将事件投递给所有订阅者,不会阻塞
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+	for _, sub := range b.subs {
+		select {
+		case sub.ch <- e:
+			sub.delivered.Add(1)
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Consume 在新的goroutine中逐个处理订阅者收到的事件,直到订阅被关闭
+func (b *Bus) Consume(sub *Subscription, handler func(Event)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for e := range sub.ch {
+			handler(e)
+		}
+	}()
+}
+
+// Close 关闭总线,等待通过 Consume 启动的订阅者处理完队列中剩余的事件
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, sub := range b.subs {
+			close(sub.ch)
+		}
+		b.subs = nil
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// Stats 返回各订阅者的投递统计
+func (b *Bus) Stats() []SubscriberStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make([]SubscriberStats, 0, len(b.subs))
+	for _, sub := range b.subs {
+		result = append(result, sub.Stats())
+	}
+	return result
+}
+
+// Events 订阅者的事件队列
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped 因队列已满而丢弃的事件数
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) Stats() SubscriberStats {
+	return SubscriberStats{
+		Name:      s.name,
+		Queued:    len(s.ch),
+		Capacity:  cap(s.ch),
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
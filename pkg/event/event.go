@@ -0,0 +1,92 @@
+package event
+
+import (
+	"netmonitor/pkg/netinfo"
+	"strconv"
+	"time"
+)
+
+// Kind 事件类型
+type Kind string
+
+const (
+	ListenerOpened       Kind = "listener_opened"        // 新增监听端口
+	ListenerClosed       Kind = "listener_closed"        // 监听端口关闭
+	ListenerOwnerChanged Kind = "listener_owner_changed" // 监听端点被其他进程重新绑定
+	ConnOpened           Kind = "conn_opened"            // 新建连接
+	ConnClosed           Kind = "conn_closed"            // 连接关闭
+	StateChanged         Kind = "state_changed"          // TCP状态变化
+	Alert                Kind = "alert"                  // 异常模式告警
+)
+
+// 元数据键
+const (
+	MetaLifetime       = "lifetime"         // 连接存活时长(time.Duration字符串),仅 conn_closed
+	MetaBaseline       = "baseline"         // "true"表示连接在启动前已存在,存活时长只是下限
+	MetaOldPID         = "old_pid"          // 原持有进程PID,仅 listener_owner_changed
+	MetaOldProcessName = "old_process_name" // 原持有进程名,仅 listener_owner_changed
+	MetaFromState      = "from_state"       // 原状态,仅 state_changed
+	MetaToState        = "to_state"         // 新状态,仅 state_changed
+	MetaAlertKind      = "alert_kind"       // 告警类型,仅 alert
+	MetaCount          = "count"            // 告警涉及的数量,仅 alert
+	MetaMessage        = "message"          // 告警说明,仅 alert
+)
+
+// Event 监控器产生的事件
+type Event struct {
+	Kind      Kind               `json:"kind"`
+	Timestamp time.Time          `json:"timestamp"`
+	Conn      netinfo.Connection `json:"connection"`
+	Metadata  map[string]string  `json:"metadata,omitempty"`
+}
+
+// New 创建事件
+func New(kind Kind, ts time.Time, conn netinfo.Connection) Event {
+	return Event{Kind: kind, Timestamp: ts, Conn: conn}
+}
+
+// With 设置一项元数据并返回事件本身,便于链式构造
+func (e Event) With(key, value string) Event {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[key] = value
+	return e
+}
+
+// Meta 读取元数据,不存在时返回空字符串
+func (e Event) Meta(key string) string {
+	return e.Metadata[key]
+}
+
+// Duration 以time.Duration读取元数据,无法解析时返回0
+func (e Event) Duration(key string) time.Duration {
+	d, _ := time.ParseDuration(e.Metadata[key])
+	return d
+}
+
+// Int 以整数读取元数据,无法解析时返回0
+func (e Event) Int(key string) int {
+	n, _ := strconv.Atoi(e.Metadata[key])
+	return n
+}
+
+// Bool 以布尔值读取元数据
+func (e Event) Bool(key string) bool {
+	b, _ := strconv.ParseBool(e.Metadata[key])
+	return b
+}
+
+// LifetimeString conn_closed 事件存活时长的可读形式,启动前已存在的连接只能给出下限
+func (e Event) LifetimeString() string {
+	d := e.Duration(MetaLifetime).Round(time.Second)
+	if e.Bool(MetaBaseline) {
+		return "≥" + d.String()
+	}
+	return d.String()
+}
+
+// IsListener 是否为监听端口相关事件
+func (e Event) IsListener() bool {
+	return e.Kind == ListenerOpened || e.Kind == ListenerClosed || e.Kind == ListenerOwnerChanged
+}
@@ -0,0 +1,142 @@
+package history
+
+import (
+	"net/netip"
+	"netmonitor/pkg/netinfo"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLimit 查询默认返回的最大记录数
+const DefaultLimit = 1000
+
+// Query 历史查询条件,零值字段表示不限
+type Query struct {
+	From     time.Time
+	To       time.Time
+	Kinds    []string     // 记录类型,例如 conn_opened, conn_closed
+	Process  string       // 进程名(不区分大小写)或PID
+	Remote   netip.Prefix // 远程地址,单个IP按 /32 或 /128 处理
+	Port     uint16       // 本地或远程端口
+	Protocol string       // tcp, udp
+	Limit    int          // 最大记录数(0表示 DefaultLimit)
+}
+
+// ParseRemote 解析IP或CIDR形式的远程地址条件,与过滤器中的网段相同,IPv4映射地址按IPv4处理
+func ParseRemote(s string) (netip.Prefix, error) {
+	if strings.TrimSpace(s) == "" {
+		return netip.Prefix{}, nil
+	}
+	return netinfo.ParsePrefix(s)
+}
+
+func (q *Query) inRange(t time.Time) bool {
+	if !q.From.IsZero() && t.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && t.After(q.To) {
+		return false
+	}
+	return true
+}
+
+func (q *Query) matchKind(kind string) bool {
+	if len(q.Kinds) == 0 {
+		return kind != KindSnapshot
+	}
+	for _, k := range q.Kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchConnection 判断连接是否满足进程、地址、端口和协议条件
+func (q *Query) MatchConnection(c netinfo.Connection) bool {
+	if q.Protocol != "" && !strings.EqualFold(q.Protocol, c.Protocol) {
+		return false
+	}
+	if q.Process != "" {
+		if pid, err := strconv.Atoi(q.Process); err == nil {
+			if int32(pid) != c.PID {
+				return false
+			}
+		} else if !strings.EqualFold(q.Process, c.ProcessName) {
+			return false
+		}
+	}
+	if q.Remote.IsValid() {
+		if !c.RemoteAddr.IsValid() || !q.Remote.Contains(c.RemoteAddr.Addr().WithZone("").Unmap()) {
+			return false
+		}
+	}
+	if q.Port != 0 && c.LocalAddr.Port() != q.Port && c.RemoteAddr.Port() != q.Port {
+		return false
+	}
+	return true
+}
+
+func (q *Query) limit() int {
+	if q.Limit <= 0 {
+		return DefaultLimit
+	}
+	return q.Limit
+}
+
+// Events 按时间顺序返回满足条件的事件记录,超过 Limit 时截断并返回 truncated=true
+func (s *Store) Events(q Query) (records []Record, truncated bool, err error) {
+	limit := q.limit()
+	for _, path := range s.overlapping(q.From, q.To) {
+		err = readSegment(path, func(r Record) bool {
+			if !q.inRange(r.Time) || !q.matchKind(r.Kind) {
+				return true
+			}
+			if r.Conn == nil || !q.MatchConnection(*r.Conn) {
+				return true
+			}
+			if len(records) >= limit {
+				truncated = true
+				return false
+			}
+			records = append(records, r)
+			return true
+		})
+		if err != nil || truncated {
+			return records, truncated, err
+		}
+	}
+	return records, false, nil
+}
+
+// SnapshotAt 返回不晚于 at 的最近一份快照,只保留满足条件的连接;没有快照时返回nil
+func (s *Store) SnapshotAt(at time.Time, q Query) (*Record, error) {
+	// 从最新的分段往前找,找到快照的分段即为最近的一份
+	var found *Record
+	paths := s.overlapping(time.Time{}, at)
+	for i := len(paths) - 1; i >= 0 && found == nil; i-- {
+		err := readSegment(paths[i], func(r Record) bool {
+			if r.Kind == KindSnapshot && !r.Time.After(at) && (found == nil || r.Time.After(found.Time)) {
+				rec := r
+				found = &rec
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+
+	conns := found.Connections[:0]
+	for _, c := range found.Connections {
+		if q.MatchConnection(c) {
+			conns = append(conns, c)
+		}
+	}
+	found.Connections = conns
+	return found, nil
+}
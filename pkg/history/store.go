@@ -0,0 +1,334 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/logger"
+	"netmonitor/pkg/netinfo"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KindSnapshot 周期快照记录的类型,其余记录的类型与事件类型相同
+const KindSnapshot = "snapshot"
+
+const (
+	indexFile     = "index.json"
+	segmentSuffix = ".seg"
+)
+
+// Record 历史库中的一条记录: 一个事件或一份完整快照
+type Record struct {
+	Kind        string               `json:"kind"`
+	Time        time.Time            `json:"time"`
+	Conn        *netinfo.Connection  `json:"conn,omitempty"`        // 事件记录
+	Metadata    map[string]string    `json:"metadata,omitempty"`    // 事件记录
+	Connections []netinfo.Connection `json:"connections,omitempty"` // 快照记录
+}
+
+// Options 历史库配置
+type Options struct {
+	SegmentSize      int64         // 单个分段文件的最大字节数(0表示64MB)
+	SegmentDuration  time.Duration // 单个分段覆盖的最长时间(0表示1小时),按时间查询时可以跳过无关分段
+	SnapshotInterval time.Duration // 周期快照间隔(0表示不保存快照)
+	Retention        time.Duration // 保留时长,超过的分段整体删除(0表示永久保留)
+}
+
+// segment 索引中的一个分段
+type segment struct {
+	File  string    `json:"file"`
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	Count int       `json:"count"`
+	Size  int64     `json:"size"`
+}
+
+// Store 仅追加的历史库。
+// 记录按行写入分段文件(每行一个JSON),分段按大小和时间切分;
+// index.json 记录已关闭分段的时间范围,查询时只读取与时间范围重叠的分段
+type Store struct {
+	dir          string
+	opts         Options
+	segments     []segment // 按时间排序,最后一个为正在写入的分段
+	active       *os.File
+	lastSnapshot time.Time
+	mu           sync.RWMutex
+}
+
+// Open 打开(或创建)目录中的历史库
+func Open(dir string, opts Options) (*Store, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = 64 << 20
+	}
+	if opts.SegmentDuration <= 0 {
+		opts.SegmentDuration = time.Hour
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建历史目录失败: %w", err)
+	}
+
+	s := &Store{dir: dir, opts: opts}
+	if err := s.loadIndex(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadIndex 读取索引,并重新扫描索引中缺失或大小不一致的分段(例如上次异常退出时正在写入的分段)
+func (s *Store) loadIndex() error {
+	indexed := make(map[string]segment)
+	if data, err := os.ReadFile(filepath.Join(s.dir, indexFile)); err == nil {
+		var segs []segment
+		if err := json.Unmarshal(data, &segs); err != nil {
+			return fmt.Errorf("解析历史索引失败: %w", err)
+		}
+		for _, seg := range segs {
+			indexed[seg.File] = seg
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("读取历史索引失败: %w", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentSuffix))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, path := range files {
+		name := filepath.Base(path)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		seg, ok := indexed[name]
+		if !ok || seg.Size != info.Size() {
+			if seg, err = scanSegment(path); err != nil {
+				return err
+			}
+		}
+		s.segments = append(s.segments, seg)
+	}
+	return nil
+}
+
+// scanSegment 读取分段文件,重建其索引项
+func scanSegment(path string) (segment, error) {
+	seg := segment{File: filepath.Base(path)}
+	err := readSegment(path, func(r Record) bool {
+		if seg.Count == 0 || r.Time.Before(seg.First) {
+			seg.First = r.Time
+		}
+		if r.Time.After(seg.Last) {
+			seg.Last = r.Time
+		}
+		seg.Count++
+		return true
+	})
+	if info, statErr := os.Stat(path); statErr == nil {
+		seg.Size = info.Size()
+	}
+	return seg, err
+}
+
+// readSegment 逐条读取分段中的记录,fn 返回false时停止。无法解析的行(写入中断的残行)被跳过
+func readSegment(path string, fn func(Record) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开历史分段失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if !fn(r) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// saveIndex 原子地写入索引
+func (s *Store) saveIndex() error {
+	data, err := json.MarshalIndent(s.segments, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, indexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入历史索引失败: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, indexFile))
+}
+
+// Append 追加一条记录
+func (s *Store) Append(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("序列化历史记录失败: %w", err)
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureSegment(r.Time, int64(len(data))); err != nil {
+		return err
+	}
+	if _, err := s.active.Write(data); err != nil {
+		return fmt.Errorf("写入历史分段失败: %w", err)
+	}
+
+	seg := &s.segments[len(s.segments)-1]
+	if seg.Count == 0 || r.Time.Before(seg.First) {
+		seg.First = r.Time
+	}
+	if r.Time.After(seg.Last) {
+		seg.Last = r.Time
+	}
+	seg.Count++
+	seg.Size += int64(len(data))
+	return nil
+}
+
+// ensureSegment 确保有可写入的分段,当前分段超过大小或时间范围时切换到新分段
+func (s *Store) ensureSegment(ts time.Time, size int64) error {
+	if n := len(s.segments); n > 0 {
+		seg := s.segments[n-1]
+		full := seg.Size > 0 && seg.Size+size > s.opts.SegmentSize
+		expired := seg.Count > 0 && ts.Sub(seg.First) >= s.opts.SegmentDuration
+		if !full && !expired {
+			if s.active != nil {
+				return nil
+			}
+			// 继续写入上次留下的分段
+			f, err := os.OpenFile(filepath.Join(s.dir, seg.File), os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				return fmt.Errorf("打开历史分段失败: %w", err)
+			}
+			s.active = f
+			return nil
+		}
+	}
+
+	if s.active != nil {
+		s.active.Close()
+		s.active = nil
+	}
+
+	// 文件名为十六进制的起始时间,按文件名排序即按时间排序
+	name := fmt.Sprintf("%016x%s", ts.UnixNano(), segmentSuffix)
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("创建历史分段失败: %w", err)
+	}
+	s.active = f
+	s.segments = append(s.segments, segment{File: name})
+
+	s.prune(ts)
+	return s.saveIndex()
+}
+
+// prune 删除超过保留时长的分段,正在写入的分段不会被删除
+func (s *Store) prune(now time.Time) {
+	if s.opts.Retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-s.opts.Retention)
+
+	kept := s.segments[:0]
+	for i, seg := range s.segments {
+		if i < len(s.segments)-1 && seg.Last.Before(cutoff) {
+			os.Remove(filepath.Join(s.dir, seg.File))
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	s.segments = kept
+}
+
+// HandleEvent 将事件写入历史库,作为事件总线的订阅者使用,写入失败时输出警告
+func (s *Store) HandleEvent(e event.Event) {
+	conn := e.Conn
+	err := s.Append(Record{
+		Kind:     string(e.Kind),
+		Time:     e.Timestamp,
+		Conn:     &conn,
+		Metadata: e.Metadata,
+	})
+	if err != nil {
+		logger.LogWarning(os.Stdout, fmt.Sprintf("写入历史库失败: %v", err))
+	}
+}
+
+// RecordSnapshot 距离上一份快照超过 SnapshotInterval 时保存快照
+func (s *Store) RecordSnapshot(snap *netinfo.Snapshot) error {
+	if s.opts.SnapshotInterval <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	due := s.lastSnapshot.IsZero() || snap.Timestamp.Sub(s.lastSnapshot) >= s.opts.SnapshotInterval
+	if due {
+		s.lastSnapshot = snap.Timestamp
+	}
+	s.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return s.Append(Record{
+		Kind:        KindSnapshot,
+		Time:        snap.Timestamp,
+		Connections: snap.Connections,
+	})
+}
+
+// Close 关闭正在写入的分段并保存索引
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active != nil {
+		s.active.Close()
+		s.active = nil
+	}
+	return s.saveIndex()
+}
+
+// Dir 历史库所在目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// overlapping 返回与时间范围重叠的分段路径,from/to 为零值表示不限
+func (s *Store) overlapping(from, to time.Time) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var paths []string
+	for _, seg := range s.segments {
+		if seg.Count == 0 {
+			continue
+		}
+		if !from.IsZero() && seg.Last.Before(from) {
+			continue
+		}
+		if !to.IsZero() && seg.First.After(to) {
+			continue
+		}
+		paths = append(paths, filepath.Join(s.dir, seg.File))
+	}
+	return paths
+}
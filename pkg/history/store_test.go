@@ -0,0 +1,193 @@
+package history
+
+import (
+	"net/netip"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/netinfo"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+
+func testConn(remote string, pid int32, name string) netinfo.Connection {
+	return netinfo.Connection{
+		Protocol: "TCP", Family: netinfo.FamilyIPv4, Status: "ESTABLISHED", PID: pid, ProcessName: name,
+		LocalAddr:  netip.MustParseAddrPort("10.0.0.5:40000"),
+		RemoteAddr: netip.MustParseAddrPort(remote),
+	}
+}
+
+func openStore(t *testing.T, dir string, opts Options) *Store {
+	t.Helper()
+	s, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// eventTimes 满足条件的记录相对 t0 的时间,按逗号分隔
+func eventTimes(t *testing.T, s *Store, q Query) string {
+	t.Helper()
+	records, _, err := s.Events(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var times []string
+	for _, r := range records {
+		times = append(times, r.Time.Sub(t0).String())
+	}
+	return strings.Join(times, ",")
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, Options{})
+	s.HandleEvent(event.New(event.ConnOpened, t0, testConn("203.0.113.7:443", 100, "curl")))
+	s.HandleEvent(event.New(event.ConnClosed, t0.Add(10*time.Second), testConn("203.0.113.7:443", 100, "curl")).
+		With(event.MetaLifetime, "10s"))
+	s.HandleEvent(event.New(event.ConnOpened, t0.Add(20*time.Second), testConn("[::ffff:198.51.100.1]:22", 200, "ssh")))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后从索引读取
+	s = openStore(t, dir, Options{})
+	defer s.Close()
+	records, truncated, err := s.Events(Query{})
+	if err != nil || truncated || len(records) != 3 {
+		t.Fatalf("Events() = %d 条记录, %v, %v", len(records), truncated, err)
+	}
+	r := records[1]
+	if r.Kind != string(event.ConnClosed) || !r.Time.Equal(t0.Add(10*time.Second)) ||
+		r.Conn == nil || *r.Conn != testConn("203.0.113.7:443", 100, "curl") || r.Metadata[event.MetaLifetime] != "10s" {
+		t.Errorf("第二条记录 %+v", r)
+	}
+
+	remote, err := ParseRemote("::ffff:198.51.100.0/120")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		q    Query
+		want string
+	}{
+		{"时间范围", Query{From: t0.Add(5 * time.Second), To: t0.Add(15 * time.Second)}, "10s"},
+		{"类型", Query{Kinds: []string{"CONN_OPENED"}}, "0s,20s"},
+		{"进程名", Query{Process: "CURL"}, "0s,10s"},
+		{"PID", Query{Process: "200"}, "20s"},
+		// IPv4映射的网段与IPv4映射的地址都按IPv4比较
+		{"远程网段", Query{Remote: remote}, "20s"},
+		{"端口", Query{Port: 443}, "0s,10s"},
+		{"数量上限", Query{Limit: 2}, "0s,10s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventTimes(t, s, tt.q); got != tt.want {
+				t.Errorf("得到 %s,期望 %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreRebuildIndex(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, Options{})
+	s.HandleEvent(event.New(event.ConnOpened, t0, testConn("203.0.113.7:443", 100, "curl")))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟索引保存之后分段又被写入(异常退出时索引中的大小过时),以及写入中断的残行
+	segs, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if len(segs) != 1 {
+		t.Fatalf("分段文件 %v,期望1个", segs)
+	}
+	f, err := os.OpenFile(segs[0], os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"kind":"conn_closed","time":"2026-03-14T13:00:00Z","conn":{"protocol":"TCP"}}` + "\n")
+	f.WriteString(`{"kind":"conn_clo`)
+	f.Close()
+
+	s = openStore(t, dir, Options{})
+	defer s.Close()
+	// 索引中的时间范围需要包含追加的记录,否则按时间查询时会跳过该分段
+	if got := eventTimes(t, s, Query{From: t0.Add(30 * time.Minute)}); got != "1h0m0s" {
+		t.Errorf("重建索引后查询得到 %q,期望 1h0m0s", got)
+	}
+	if got := eventTimes(t, s, Query{}); got != "0s,1h0m0s" {
+		t.Errorf("重建索引后全部记录 %q", got)
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, Options{SegmentDuration: 10 * time.Minute, Retention: time.Hour})
+	defer s.Close()
+
+	for _, offset := range []time.Duration{0, 5 * time.Minute, 40 * time.Minute, 90 * time.Minute} {
+		s.HandleEvent(event.New(event.ConnOpened, t0.Add(offset), testConn("203.0.113.7:443", 100, "curl")))
+	}
+
+	// 90分钟时切换到新分段,最后记录早于30分钟的第一个分段被删除
+	segs, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if len(segs) != 2 {
+		t.Errorf("剩余分段 %v,期望2个", segs)
+	}
+	if got := eventTimes(t, s, Query{}); got != "40m0s,1h30m0s" {
+		t.Errorf("剩余记录 %q,期望 40m0s,1h30m0s", got)
+	}
+}
+
+func TestSnapshotAt(t *testing.T) {
+	s := openStore(t, t.TempDir(), Options{SnapshotInterval: time.Minute})
+	defer s.Close()
+
+	snap := func(offset time.Duration, conns ...netinfo.Connection) {
+		t.Helper()
+		if err := s.RecordSnapshot(&netinfo.Snapshot{Timestamp: t0.Add(offset), Connections: conns}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	curl := testConn("203.0.113.7:443", 100, "curl")
+	ssh := testConn("198.51.100.1:22", 200, "ssh")
+	snap(0, curl, ssh)
+	snap(30*time.Second, curl) // 未到间隔,不保存
+	snap(time.Minute, ssh)
+
+	tests := []struct {
+		at   time.Duration
+		q    Query
+		want string // 快照时间和连接的进程名,没有快照时为空
+	}{
+		{-time.Second, Query{}, ""},
+		{50 * time.Second, Query{}, "0s curl,ssh"},
+		{50 * time.Second, Query{Process: "ssh"}, "0s ssh"},
+		{time.Hour, Query{}, "1m0s ssh"},
+		{time.Hour, Query{Process: "curl"}, "1m0s "},
+	}
+	for _, tt := range tests {
+		r, err := s.SnapshotAt(t0.Add(tt.at), tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if r != nil {
+			var names []string
+			for _, c := range r.Connections {
+				names = append(names, c.ProcessName)
+			}
+			got = r.Time.Sub(t0).String() + " " + strings.Join(names, ",")
+		}
+		if got != tt.want {
+			t.Errorf("SnapshotAt(%s, %+v) = %q,期望 %q", tt.at, tt.q, got, tt.want)
+		}
+	}
+}
@@ -6,23 +6,34 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // CleanupConfig 日志清理配置
 type CleanupConfig struct {
-	Enabled          bool
+	Enabled         bool
 	RetentionDays   int
 	CompressEnabled bool
+	MaxTotalSize    int64 // 每个目录中日志的总字节数上限(0表示不限制)
 }
 
-// CleanupOldLogs 清理过期日志并压缩旧日志
+// cleanupMu 避免定时清理与切分后的清理同时处理同一个文件
+var cleanupMu sync.Mutex
+
+// CleanupOldLogs 清理过期日志并压缩旧日志。
+// 正在写入的日志文件(见 IsActive)不会被压缩或删除
 func CleanupOldLogs(dir string, config CleanupConfig) error {
 	if !config.Enabled {
 		return nil
 	}
 
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+
 	// 遍历目录中的所有文件
 	files, err := os.ReadDir(dir)
 	if err != nil {
@@ -54,8 +65,8 @@ func CleanupOldLogs(dir string, config CleanupConfig) error {
 			continue
 		}
 
-		// 检查是否为今天或昨天的日志文件
-		if !isLogFile(file.Name()) {
+		// 跳过非日志文件和正在写入的文件
+		if !isLogFile(file.Name()) || IsActive(filePath) {
 			continue
 		}
 
@@ -65,27 +76,158 @@ func CleanupOldLogs(dir string, config CleanupConfig) error {
 			continue
 		}
 
-		// 如果是昨天的日志,进行压缩
-		yesterday := now.AddDate(0, 0, -1)
-		if logDate.Before(yesterday.Truncate(24 * time.Hour)) {
-			if config.CompressEnabled {
-				err := compressLog(filePath)
-				if err == nil {
-					fmt.Printf("已压缩日志: %s\n", file.Name())
-				}
-			}
-		}
-
 		// 删除过期的日志
 		if logDate.Before(cutoffDate) {
 			os.Remove(filePath)
-			fmt.Printf("已删除过期日志: %s\n", file.Name())
+			cleanupInfo(dir, fmt.Sprintf("已删除过期日志: %s", filePath))
+			continue
+		}
+
+		// 已切分出去的日志不会再被写入,进行压缩
+		if config.CompressEnabled {
+			if err := compressLog(filePath); err != nil {
+				cleanupWarning(dir, fmt.Sprintf("压缩日志失败: %v", err))
+			} else {
+				cleanupInfo(dir, fmt.Sprintf("已压缩日志: %s", filePath))
+			}
+		}
+	}
+
+	return enforceQuota(dir, config.MaxTotalSize)
+}
+
+// logFile 日志目录中的一个文件
+type logFile struct {
+	path       string
+	date       time.Time
+	seq        int
+	size       int64
+	compressed bool
+}
+
+// listLogFiles 列出目录中的日志文件(.log 和 .log.gz)
+func listLogFiles(dir string) ([]logFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	var files []logFile
+	for _, entry := range entries {
+		name := entry.Name()
+		compressed := strings.HasSuffix(name, ".log.gz")
+		if entry.IsDir() || !(compressed || isLogFile(name)) {
+			continue
+		}
+		base := strings.TrimSuffix(name, ".gz")
+		date, err := parseLogDate(base)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{
+			path:       filepath.Join(dir, name),
+			date:       date,
+			seq:        parseLogSegment(base),
+			size:       info.Size(),
+			compressed: compressed,
+		})
+	}
+	return files, nil
+}
+
+// enforceQuota 目录中日志的总大小超过 maxTotal 时,按日期和分段序号从旧到新删除:
+// 先删除 .log.gz,仍然超出时再删除未压缩的日志,正在写入的文件不会被删除
+func enforceQuota(dir string, maxTotal int64) error {
+	if maxTotal <= 0 {
+		return nil
+	}
+
+	files, err := listLogFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	if total <= maxTotal {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.compressed != b.compressed {
+			return a.compressed
+		}
+		if !a.date.Equal(b.date) {
+			return a.date.Before(b.date)
+		}
+		return a.seq < b.seq
+	})
+
+	for _, f := range files {
+		if total <= maxTotal {
+			break
 		}
+		if IsActive(f.path) {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			cleanupWarning(dir, fmt.Sprintf("删除日志失败: %v", err))
+			continue
+		}
+		total -= f.size
+		cleanupInfo(dir, fmt.Sprintf("日志目录超出大小上限 %d 字节,已删除: %s", maxTotal, f.path))
 	}
 
+	if total > maxTotal {
+		cleanupWarning(dir, fmt.Sprintf("日志目录 %s 仍占用 %d 字节,超出上限 %d 字节", dir, total, maxTotal))
+	}
 	return nil
 }
 
+// cleanupSink 写入 dir 的日志输出端,dir 不属于任何已初始化的日志时返回nil
+func cleanupSink(dir string) *Sink {
+	dir = filepath.Clean(dir)
+	for _, s := range sinks() {
+		if w, ok := s.file.(*RotatingWriter); ok && filepath.Clean(w.dir) == dir {
+			return s
+		}
+	}
+	return nil
+}
+
+// cleanupInfo 将清理结果写入该目录的日志(文本行或JSON记录)和syslog,
+// 目录不属于任何已初始化的日志时输出到标准输出
+func cleanupInfo(dir, message string) {
+	sink := cleanupSink(dir)
+	if sink == nil {
+		LogInfo(os.Stdout, message)
+	} else {
+		LogInfo(sink, message)
+		sink.WriteRecord(newCleanupRecord(message))
+	}
+	if Syslog != nil {
+		Syslog.Info(message)
+	}
+}
+
+// cleanupWarning 与 cleanupInfo 相同,以警告级别输出
+func cleanupWarning(dir, message string) {
+	sink := cleanupSink(dir)
+	if sink == nil {
+		LogWarning(os.Stdout, message)
+		return
+	}
+	LogWarning(sink, message)
+	sink.WriteRecord(newCleanupRecord(message))
+}
+
 // isLogFile 检查是否为日志文件
 func isLogFile(filename string) bool {
 	return strings.HasSuffix(filename, ".log")
@@ -93,11 +235,18 @@ func isLogFile(filename string) bool {
 
 // parseLogDate 从日志文件名中解析日期
 func parseLogDate(filename string) (time.Time, error) {
-	// 去掉.log后缀
-	dateStr := strings.TrimSuffix(filename, ".log")
+	// 去掉.log后缀和分段序号(2006-01-02.1.log)
+	dateStr, _, _ := strings.Cut(strings.TrimSuffix(filename, ".log"), ".")
 
 	// 解析日期(格式: 2006-01-02)
-	return time.Parse("2006-01-02", dateStr)
+	return time.ParseInLocation("2006-01-02", dateStr, time.Local)
+}
+
+// parseLogSegment 从日志文件名中解析分段序号,当天第一个文件为0
+func parseLogSegment(filename string) int {
+	_, seq, _ := strings.Cut(strings.TrimSuffix(filename, ".log"), ".")
+	n, _ := strconv.Atoi(seq)
+	return n
 }
 
 // compressLog 压缩日志文件
@@ -109,6 +258,11 @@ func compressLog(filePath string) error {
 	}
 	defer sourceFile.Close()
 
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		return fmt.Errorf("读取源文件信息失败: %w", err)
+	}
+
 	// 创建压缩文件
 	gzPath := filePath + ".gz"
 	gzFile, err := os.Create(gzPath)
@@ -119,41 +273,67 @@ func compressLog(filePath string) error {
 
 	// 创建gzip写入器
 	gzWriter := gzip.NewWriter(gzFile)
-	defer gzWriter.Close()
 
 	// 复制数据
 	_, err = io.Copy(gzWriter, sourceFile)
+	if err == nil {
+		err = gzWriter.Close()
+	}
 	if err != nil {
 		os.Remove(gzPath)
 		return fmt.Errorf("压缩文件失败: %w", err)
 	}
 
+	// 保留原文件的修改时间,过期判断以日志内容的时间为准
+	os.Chtimes(gzPath, sourceInfo.ModTime(), sourceInfo.ModTime())
+
 	// 压缩成功后删除原文件
 	os.Remove(filePath)
 
 	return nil
 }
 
-// StartCleanupTask 启动定时清理任务
+var (
+	cleanupConfig   CleanupConfig
+	cleanupConfigMu sync.Mutex
+)
+
+// SetCleanupConfig 修改定时清理任务和切分后清理使用的配置
+func SetCleanupConfig(config CleanupConfig) {
+	cleanupConfigMu.Lock()
+	cleanupConfig = config
+	cleanupConfigMu.Unlock()
+}
+
+// CurrentCleanupConfig 当前生效的清理配置
+func CurrentCleanupConfig() CleanupConfig {
+	cleanupConfigMu.Lock()
+	defer cleanupConfigMu.Unlock()
+	return cleanupConfig
+}
+
+// StartCleanupTask 启动定时清理任务,每次清理时读取 CurrentCleanupConfig,
+// 运行中通过 SetCleanupConfig 修改的配置在下一次清理时生效
 func StartCleanupTask(listenerDir, establishedDir string, config CleanupConfig) {
-	if !config.Enabled {
-		return
+	SetCleanupConfig(config)
+
+	cleanup := func() {
+		if config := CurrentCleanupConfig(); config.Enabled {
+			CleanupOldLogs(listenerDir, config)
+			CleanupOldLogs(establishedDir, config)
+		}
 	}
 
 	// 立即执行一次清理
-	go func() {
-		CleanupOldLogs(listenerDir, config)
-		CleanupOldLogs(establishedDir, config)
-	}()
+	go cleanup()
 
 	// 每天执行一次清理
-	ticker := time.NewTicker(24 * time.Hour)
-	defer ticker.Stop()
-
 	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
 		for range ticker.C {
-			CleanupOldLogs(listenerDir, config)
-			CleanupOldLogs(establishedDir, config)
+			cleanup()
 		}
 	}()
 }
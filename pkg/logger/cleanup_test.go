@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnforceQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxTotal int64
+		want     []string // 剩余的文件
+	}{
+		// 先按日期和序号删除最旧的 .gz,未压缩的旧日志保留
+		{"压缩文件优先", 250, []string{"2026-03-09.log", "2026-03-12.log", "notes.txt"}},
+		// .gz 删完仍然超出时删除未压缩的日志,正在写入的文件不删除
+		{"保留正在写入的文件", 50, []string{"2026-03-12.log", "notes.txt"}},
+		{"未超出上限", 500, []string{
+			"2026-03-09.log", "2026-03-10.log.gz", "2026-03-11.1.log.gz", "2026-03-11.log.gz",
+			"2026-03-12.log", "notes.txt",
+		}},
+		{"不限制", 0, []string{
+			"2026-03-09.log", "2026-03-10.log.gz", "2026-03-11.1.log.gz", "2026-03-11.log.gz",
+			"2026-03-12.log", "notes.txt",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// 每个日志文件100字节,共500字节;非日志文件不计入也不删除
+			for _, name := range []string{
+				"2026-03-09.log", "2026-03-10.log.gz", "2026-03-11.1.log.gz", "2026-03-11.log.gz",
+				"2026-03-12.log", "notes.txt",
+			} {
+				if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 100), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			active := filepath.Join(dir, "2026-03-12.log")
+			setActive(active, true)
+			defer setActive(active, false)
+
+			if err := enforceQuota(dir, tt.maxTotal); err != nil {
+				t.Fatal(err)
+			}
+			if got := readDir(t, dir); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("剩余文件 %v,期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanupReportsToSink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "2026-03-10.log.gz"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewRotatingWriter(dir, RotateOptions{
+		Now: func() time.Time { return time.Date(2026, 3, 14, 12, 0, 0, 0, time.Local) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 清理结果写入该目录自己的日志,JSON格式下为一条 log_cleanup 记录
+	saved := ListenerWriter
+	ListenerWriter = NewSink(w, nil, FormatJSON)
+	defer func() { ListenerWriter = saved }()
+
+	if err := enforceQuota(dir, 50); err != nil {
+		t.Fatal(err)
+	}
+	got := readFile(t, w.ActivePath())
+	if !strings.Contains(got, `"event":"log_cleanup"`) || !strings.Contains(got, "2026-03-10.log.gz") {
+		t.Errorf("日志内容 %q,期望包含清理记录", got)
+	}
+}
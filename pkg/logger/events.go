@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"fmt"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/netinfo"
+	"os"
+)
+
+// HandleEvent 将总线上的事件写入对应的日志,作为事件总线的订阅者使用。
+// 监听端口相关事件写入 ListenerWriter,其余写入 EstablishedWriter;
+// JSON格式下文件中写入事件记录,控制台仍输出可读文本
+func HandleEvent(e event.Event) {
+	sink := EstablishedWriter
+	if e.IsListener() {
+		sink = ListenerWriter
+	}
+	if err := sink.WriteRecord(NewRecord(e, Hostname)); err != nil {
+		logWarning(os.Stdout, fmt.Sprintf("写入JSON日志失败: %v", err))
+	}
+
+	c := e.Conn
+	local := netinfo.FormatAddr(c.LocalAddr)
+	remote := netinfo.FormatAddr(c.RemoteAddr)
+
+	switch e.Kind {
+	case event.ListenerOpened:
+		LogConnection(ListenerWriter, "LISTEN", c.Protocol, local, "", c.PID, c.ProcessName, true)
+	case event.ListenerClosed:
+		LogConnection(ListenerWriter, "LISTEN", c.Protocol, local, "", c.PID, c.ProcessName, false)
+	case event.ListenerOwnerChanged:
+		LogOwnerChange(ListenerWriter, c.Protocol, local,
+			int32(e.Int(event.MetaOldPID)), e.Meta(event.MetaOldProcessName), c.PID, c.ProcessName)
+	case event.ConnOpened:
+		LogConnection(EstablishedWriter, "", c.Protocol, local, remote, c.PID, c.ProcessName, true)
+	case event.ConnClosed:
+		LogConnectionClosed(EstablishedWriter, c.Protocol, local, remote, c.PID, c.ProcessName, e.LifetimeString())
+	case event.StateChanged:
+		LogStateChange(EstablishedWriter, c.Protocol, local, remote,
+			e.Meta(event.MetaFromState), e.Meta(event.MetaToState), c.PID, c.ProcessName)
+	case event.Alert:
+		logWarning(EstablishedWriter, e.Meta(event.MetaMessage))
+	}
+}
+
+// EventMessage 事件的可读文本,与文本日志中的内容一致(不含时间和颜色)
+func EventMessage(e event.Event) string {
+	c := e.Conn
+	local := netinfo.FormatAddr(c.LocalAddr)
+	remote := netinfo.FormatAddr(c.RemoteAddr)
+
+	switch e.Kind {
+	case event.ListenerOpened:
+		return connectionMessage("LISTEN", c.Protocol, local, "", c.PID, c.ProcessName, true)
+	case event.ListenerClosed:
+		return connectionMessage("LISTEN", c.Protocol, local, "", c.PID, c.ProcessName, false)
+	case event.ListenerOwnerChanged:
+		return ownerChangeMessage(c.Protocol, local,
+			int32(e.Int(event.MetaOldPID)), e.Meta(event.MetaOldProcessName), c.PID, c.ProcessName)
+	case event.ConnOpened:
+		return connectionMessage("", c.Protocol, local, remote, c.PID, c.ProcessName, true)
+	case event.ConnClosed:
+		return connectionClosedMessage(c.Protocol, local, remote, c.PID, c.ProcessName, e.LifetimeString())
+	case event.StateChanged:
+		return stateChangeMessage(c.Protocol, local, remote,
+			e.Meta(event.MetaFromState), e.Meta(event.MetaToState), c.PID, c.ProcessName)
+	case event.Alert:
+		return e.Meta(event.MetaMessage)
+	}
+	return string(e.Kind)
+}
@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"netmonitor/pkg/event"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// 日志文件格式
+const (
+	FormatText = "text" // 可读文本,每个事件一行
+	FormatJSON = "json" // NDJSON,每个事件一个JSON对象
+)
+
+// RecordCleanup 日志清理结果的记录类型,记录中只有时间、主机和 message
+const RecordCleanup = "log_cleanup"
+
+// Record JSON格式日志中的一条事件记录
+type Record struct {
+	Timestamp      string  `json:"timestamp"` // RFC3339,带纳秒
+	Event          string  `json:"event"`
+	Protocol       string  `json:"protocol,omitempty"`
+	Family         string  `json:"family,omitempty"`
+	Status         string  `json:"status,omitempty"`
+	LocalIP        string  `json:"local_ip,omitempty"`
+	LocalPort      uint16  `json:"local_port,omitempty"`
+	RemoteIP       string  `json:"remote_ip,omitempty"`
+	RemotePort     uint16  `json:"remote_port,omitempty"`
+	PID            int32   `json:"pid"`
+	ProcessName    string  `json:"process_name"`
+	Host           string  `json:"host"`
+	Lifetime       float64 `json:"lifetime_seconds,omitempty"` // 仅 conn_closed
+	Baseline       bool    `json:"baseline,omitempty"`         // 仅 conn_closed,存活时长只是下限
+	OldPID         int32   `json:"old_pid,omitempty"`          // 仅 listener_owner_changed
+	OldProcessName string  `json:"old_process_name,omitempty"` // 仅 listener_owner_changed
+	FromState      string  `json:"from_state,omitempty"`       // 仅 state_changed
+	ToState        string  `json:"to_state,omitempty"`         // 仅 state_changed
+	AlertKind      string  `json:"alert_kind,omitempty"`       // 仅 alert
+	Count          int     `json:"count,omitempty"`            // 仅 alert
+	Message        string  `json:"message,omitempty"`          // 仅 alert 和 log_cleanup
+}
+
+// NewRecord 将事件转换为JSON日志记录
+func NewRecord(e event.Event, host string) Record {
+	c := e.Conn
+	r := Record{
+		Timestamp:   e.Timestamp.Format(time.RFC3339Nano),
+		Event:       string(e.Kind),
+		Protocol:    c.Protocol,
+		Family:      c.Family,
+		Status:      c.Status,
+		PID:         c.PID,
+		ProcessName: c.ProcessName,
+		Host:        host,
+	}
+	if c.LocalAddr.IsValid() {
+		r.LocalIP = c.LocalAddr.Addr().String()
+		r.LocalPort = c.LocalAddr.Port()
+	}
+	if c.RemoteAddr.IsValid() {
+		r.RemoteIP = c.RemoteAddr.Addr().String()
+		r.RemotePort = c.RemoteAddr.Port()
+	}
+
+	switch e.Kind {
+	case event.ConnClosed:
+		r.Lifetime = e.Duration(event.MetaLifetime).Seconds()
+		r.Baseline = e.Bool(event.MetaBaseline)
+	case event.ListenerOwnerChanged:
+		r.OldPID = int32(e.Int(event.MetaOldPID))
+		r.OldProcessName = e.Meta(event.MetaOldProcessName)
+	case event.StateChanged:
+		r.FromState = e.Meta(event.MetaFromState)
+		r.ToState = e.Meta(event.MetaToState)
+	case event.Alert:
+		r.AlertKind = e.Meta(event.MetaAlertKind)
+		r.Count = e.Int(event.MetaCount)
+		r.Message = e.Meta(event.MetaMessage)
+	}
+	return r
+}
+
+// newCleanupRecord 日志清理结果的记录
+func newCleanupRecord(message string) Record {
+	return Record{
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Event:     RecordCleanup,
+		Host:      Hostname,
+		Message:   message,
+	}
+}
+
+// ansiPattern 匹配ANSI颜色控制序列
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// StripANSI 去除文本中的ANSI颜色代码
+func StripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// Sink 一个日志目录的输出端: 日志文件加上可选的控制台。
+// 控制台保留颜色,写入文件的内容总是去除颜色代码;
+// JSON格式下文件中只写事件记录,普通文本只输出到控制台
+type Sink struct {
+	file    io.Writer
+	console io.Writer // nil 表示不输出到控制台
+	format  string
+	mu      sync.Mutex
+}
+
+func NewSink(file, console io.Writer, format string) *Sink {
+	return &Sink{file: file, console: console, format: format}
+}
+
+// Write 写入一行文本日志
+func (s *Sink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.console != nil {
+		s.console.Write(p)
+	}
+	if s.format == FormatJSON {
+		return len(p), nil
+	}
+	if _, err := io.WriteString(s.file, StripANSI(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 关闭日志文件
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.file.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SetFormat 修改写入文件的格式
+func (s *Sink) SetFormat(format string) {
+	s.mu.Lock()
+	s.format = format
+	s.mu.Unlock()
+}
+
+// SetConsole 修改控制台输出,nil 表示不输出到控制台
+func (s *Sink) SetConsole(console io.Writer) {
+	s.mu.Lock()
+	s.console = console
+	s.mu.Unlock()
+}
+
+// WriteRecord 以一行JSON写入事件记录,文本格式下忽略
+func (s *Sink) WriteRecord(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.format != FormatJSON {
+		return nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
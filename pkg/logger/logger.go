@@ -4,16 +4,25 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	ListenerWriter   io.Writer
-	EstablishedWriter io.Writer
-	ColorEnabled      bool
-	LogToConsole      bool
+	ListenerWriter    *Sink
+	EstablishedWriter *Sink
+	ColorEnabled      atomic.Bool // 运行中可能被配置重新加载修改
+	Hostname          string      // 写入JSON日志的主机名
+)
+
+// 运行中可能被配置重新加载修改,通过 SetFormat/SetConsole 修改,
+// 通过 CurrentFormat/ConsoleEnabled 读取
+var (
+	logToConsole bool
+	format       string // 日志文件格式: text, json
+	outputMu     sync.Mutex
 )
 
 // ANSI颜色代码
@@ -29,39 +38,139 @@ const (
 	ColorGray   = "\033[90m"
 )
 
-func InitLogger(listenerDir, establishedDir string, colorEnabled, logToConsole bool) error {
-	ColorEnabled = colorEnabled
-	LogToConsole = logToConsole
+// InitLogger 初始化监听端口日志和已建立连接日志,日志文件按 rotate 配置切分
+func InitLogger(listenerDir, establishedDir, fileFormat string, colorEnabled, console bool, rotate RotateOptions) error {
+	f, err := normalizeFormat(fileFormat)
+	if err != nil {
+		return err
+	}
+	ColorEnabled.Store(colorEnabled)
+	outputMu.Lock()
+	logToConsole, format = console, f
+	outputMu.Unlock()
+	Hostname, _ = os.Hostname()
 
-	if err := createLogWriter(listenerDir, &ListenerWriter); err != nil {
+	if err := createLogWriter(listenerDir, rotate, &ListenerWriter); err != nil {
 		return err
 	}
-	return createLogWriter(establishedDir, &EstablishedWriter)
+	return createLogWriter(establishedDir, rotate, &EstablishedWriter)
 }
 
-func createLogWriter(dir string, writer *io.Writer) error {
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+// InitConsoleLogger 初始化只输出到控制台的日志,不创建日志文件
+func InitConsoleLogger(colorEnabled bool) {
+	ColorEnabled.Store(colorEnabled)
+	outputMu.Lock()
+	logToConsole, format = true, FormatText
+	outputMu.Unlock()
+	Hostname, _ = os.Hostname()
+
+	ListenerWriter = NewSink(io.Discard, os.Stdout, FormatText)
+	EstablishedWriter = NewSink(io.Discard, os.Stdout, FormatText)
+}
+
+// normalizeFormat 检查日志格式,留空表示文本格式
+func normalizeFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
 	}
+	return "", fmt.Errorf("未知的日志格式: %s", format)
+}
 
-	logFile := filepath.Join(dir, time.Now().Format("2006-01-02")+".log")
-	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// SetFormat 修改日志文件格式,之后写入的记录使用新格式
+func SetFormat(fileFormat string) error {
+	f, err := normalizeFormat(fileFormat)
 	if err != nil {
 		return err
 	}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	format = f
+	for _, s := range sinks() {
+		s.SetFormat(f)
+	}
+	return nil
+}
+
+// CurrentFormat 当前的日志文件格式
+func CurrentFormat() string {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	return format
+}
+
+// SetConsole 开启或关闭控制台输出
+func SetConsole(enabled bool) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	logToConsole = enabled
+	var console io.Writer
+	if enabled {
+		console = os.Stdout
+	}
+	for _, s := range sinks() {
+		s.SetConsole(console)
+	}
+}
 
-	// 总是写入文件
-	writers := []io.Writer{f}
+// ConsoleEnabled 日志是否同时输出到控制台
+func ConsoleEnabled() bool {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	return logToConsole
+}
 
-	// 根据配置决定是否输出到控制台
-	if LogToConsole {
-		writers = append(writers, os.Stdout)
+// SetMaxFileSize 修改日志文件的切分大小,0表示只在每天零点切分
+func SetMaxFileSize(size int64) {
+	for _, s := range sinks() {
+		if w, ok := s.file.(*RotatingWriter); ok {
+			w.SetMaxSize(size)
+		}
 	}
+}
 
-	*writer = io.MultiWriter(writers...)
+// sinks 已初始化的日志输出端
+func sinks() []*Sink {
+	var result []*Sink
+	for _, s := range []*Sink{ListenerWriter, EstablishedWriter} {
+		if s != nil {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func createLogWriter(dir string, rotate RotateOptions, writer **Sink) error {
+	f, err := NewRotatingWriter(dir, rotate)
+	if err != nil {
+		return err
+	}
+
+	// 总是写入文件,根据配置决定是否输出到控制台
+	var console io.Writer
+	if ConsoleEnabled() {
+		console = os.Stdout
+	}
+
+	*writer = NewSink(f, console, CurrentFormat())
 	return nil
 }
 
+// Close 关闭日志文件和syslog连接
+func Close() {
+	if Syslog != nil {
+		Syslog.Close()
+	}
+	if ListenerWriter != nil {
+		ListenerWriter.Close()
+	}
+	if EstablishedWriter != nil {
+		EstablishedWriter.Close()
+	}
+}
+
 func LogMessage(writer io.Writer, message string) {
 	entry := fmt.Sprintf("[%s] %s\n", time.Now().Format("2006-01-02 15:04:05"), message)
 	writer.Write([]byte(entry))
@@ -71,26 +180,68 @@ func LogMessage(writer io.Writer, message string) {
 func LogConnection(writer io.Writer, connType, protocol, localAddr, remoteAddr string, pid int32, processName string, isNew bool) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 
-	var symbol, color string
+	color := ColorRed
 	if isNew {
-		symbol = "[+]"
 		color = ColorGreen
-	} else {
-		symbol = "[-]"
-		color = ColorRed
 	}
 
-	var message string
+	writeColored(writer, timestamp, color, connectionMessage(connType, protocol, localAddr, remoteAddr, pid, processName, isNew))
+}
+
+func connectionMessage(connType, protocol, localAddr, remoteAddr string, pid int32, processName string, isNew bool) string {
+	symbol := "[-]"
+	if isNew {
+		symbol = "[+]"
+	}
+
 	if connType == "LISTEN" {
-		message = fmt.Sprintf("%s %s %s %s PID:%d %s",
+		return fmt.Sprintf("%s %s %s %s PID:%d %s",
 			symbol, connType, protocol, localAddr, pid, processName)
-	} else {
-		message = fmt.Sprintf("%s %s %s → %s PID:%d %s",
-			symbol, protocol, localAddr, remoteAddr, pid, processName)
 	}
+	return fmt.Sprintf("%s %s %s → %s PID:%d %s",
+		symbol, protocol, localAddr, remoteAddr, pid, processName)
+}
+
+// 带存活时长的连接关闭日志
+func LogConnectionClosed(writer io.Writer, protocol, localAddr, remoteAddr string, pid int32, processName, lifetime string) {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+
+	writeColored(writer, timestamp, ColorRed, connectionClosedMessage(protocol, localAddr, remoteAddr, pid, processName, lifetime))
+}
+
+func connectionClosedMessage(protocol, localAddr, remoteAddr string, pid int32, processName, lifetime string) string {
+	return fmt.Sprintf("[-] %s %s → %s PID:%d %s 存活:%s",
+		protocol, localAddr, remoteAddr, pid, processName, lifetime)
+}
+
+// TCP状态变化
+func LogStateChange(writer io.Writer, protocol, localAddr, remoteAddr, from, to string, pid int32, processName string) {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+
+	writeColored(writer, timestamp, ColorPurple, stateChangeMessage(protocol, localAddr, remoteAddr, from, to, pid, processName))
+}
+
+func stateChangeMessage(protocol, localAddr, remoteAddr, from, to string, pid int32, processName string) string {
+	return fmt.Sprintf("[*] %s %s → %s %s→%s PID:%d %s",
+		protocol, localAddr, remoteAddr, from, to, pid, processName)
+}
+
+// 监听端点持有进程变化
+func LogOwnerChange(writer io.Writer, protocol, localAddr string, oldPID int32, oldName string, newPID int32, newName string) {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
 
+	writeColored(writer, timestamp, ColorYellow, ownerChangeMessage(protocol, localAddr, oldPID, oldName, newPID, newName))
+}
+
+func ownerChangeMessage(protocol, localAddr string, oldPID int32, oldName string, newPID int32, newName string) string {
+	return fmt.Sprintf("[~] LISTEN %s %s PID:%d %s → PID:%d %s",
+		protocol, localAddr, oldPID, oldName, newPID, newName)
+}
+
+// writeColored 按颜色配置输出一行日志
+func writeColored(writer io.Writer, timestamp, color, message string) {
 	// Windows终端可能不支持ANSI颜色,需要检查
-	if ColorEnabled && isColorSupported() {
+	if ColorEnabled.Load() && isColorSupported() {
 		entry := fmt.Sprintf("[%s] %s%s%s\n", timestamp, color, message, ColorReset)
 		writer.Write([]byte(entry))
 	} else {
@@ -114,7 +265,7 @@ func isColorSupported() bool {
 func LogStats(writer io.Writer, establishedCount, listenerCount, newConnections, closedConnections, newListeners, closedListeners int) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 
-	if ColorEnabled && isColorSupported() {
+	if ColorEnabled.Load() && isColorSupported() {
 		stats := fmt.Sprintf("[%s] %s=== 统计: 活跃连接=%d 监听端口=%d 新建=%d 关闭=%d ===%s\n",
 			timestamp, ColorCyan, establishedCount, listenerCount, newConnections, closedConnections, ColorReset)
 		writer.Write([]byte(stats))
@@ -129,7 +280,7 @@ func LogStats(writer io.Writer, establishedCount, listenerCount, newConnections,
 func LogInfo(writer io.Writer, message string) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 
-	if ColorEnabled && isColorSupported() {
+	if ColorEnabled.Load() && isColorSupported() {
 		entry := fmt.Sprintf("[%s] %s[INFO]%s %s\n", timestamp, ColorBlue, ColorReset, message)
 		writer.Write([]byte(entry))
 	} else {
@@ -138,15 +289,23 @@ func LogInfo(writer io.Writer, message string) {
 	}
 }
 
-// 警告输出
+// 警告输出,启用syslog时同时发送到syslog
 func LogWarning(writer io.Writer, message string) {
+	logWarning(writer, message)
+	if Syslog != nil {
+		Syslog.Warning(message)
+	}
+}
+
+// logWarning 只输出到 writer 的警告
+func logWarning(writer io.Writer, message string) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 
-	if ColorEnabled && isColorSupported() {
+	if ColorEnabled.Load() && isColorSupported() {
 		entry := fmt.Sprintf("[%s] %s[WARN]%s %s\n", timestamp, ColorYellow, ColorReset, message)
 		writer.Write([]byte(entry))
 	} else {
 		entry := fmt.Sprintf("[%s] [WARN] %s\n", timestamp, message)
 		writer.Write([]byte(entry))
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,206 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateOptions 日志切分配置
+type RotateOptions struct {
+	MaxSize  int64             // 单个文件的最大字节数,超过后切分为同一天的下一个分段(0表示只按天切分)
+	Now      func() time.Time  // 时钟,留空使用 time.Now,测试时可注入
+	OnRotate func(path string) // 旧文件关闭后调用,用于压缩和清理
+}
+
+// RotatingWriter 按本地日期(以及可选的文件大小)切分的日志文件。
+// 当天第一个文件为 2006-01-02.log,按大小切分后依次为 2006-01-02.1.log、2006-01-02.2.log ...
+// 每次 Write 完整写入同一个文件,调用方按行写入即可保证切分时不会拆开或丢失日志行
+type RotatingWriter struct {
+	dir  string
+	opts RotateOptions
+	file *os.File
+	path string
+	day  string
+	seq  int
+	size int64
+	mu   sync.Mutex
+}
+
+// NewRotatingWriter 在 dir 中打开当天的日志文件,已存在时追加写入
+func NewRotatingWriter(dir string, opts RotateOptions) (*RotatingWriter, error) {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	w := &RotatingWriter{dir: dir, opts: opts}
+	day := opts.Now().Format("2006-01-02")
+	if err := w.open(day, lastSegment(dir, day)); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// segmentName 日志分段的文件名
+func segmentName(day string, seq int) string {
+	if seq == 0 {
+		return day + ".log"
+	}
+	return fmt.Sprintf("%s.%d.log", day, seq)
+}
+
+// lastSegment 返回某天应继续写入的分段序号: 最后一个分段未压缩时继续追加,
+// 已被压缩时使用下一个序号,避免再次压缩时覆盖已有的 .log.gz
+func lastSegment(dir, day string) int {
+	last, compressed := -1, false
+	matches, _ := filepath.Glob(filepath.Join(dir, day+"*.log*"))
+	for _, m := range matches {
+		name := filepath.Base(m)
+		gz := strings.HasSuffix(name, ".log.gz")
+		s := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".log")
+		s = strings.TrimPrefix(s, day)
+
+		n := 0
+		if s != "" {
+			var err error
+			if n, err = strconv.Atoi(strings.TrimPrefix(s, ".")); err != nil || !strings.HasPrefix(s, ".") {
+				continue
+			}
+		}
+		if n > last || (n == last && !gz) {
+			last, compressed = n, gz
+		}
+	}
+
+	switch {
+	case last < 0:
+		return 0
+	case compressed:
+		return last + 1
+	default:
+		return last
+	}
+}
+
+func (w *RotatingWriter) open(day string, seq int) error {
+	path := filepath.Join(w.dir, segmentName(day, seq))
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	setActive(path, true)
+	w.file = f
+	w.path = path
+	w.day = day
+	w.seq = seq
+	w.size = info.Size()
+	return nil
+}
+
+// rotate 关闭当前文件并打开新文件,旧文件交给 OnRotate 处理
+func (w *RotatingWriter) rotate(day string, seq int) error {
+	oldFile, oldPath := w.file, w.path
+	if err := w.open(day, seq); err != nil {
+		// 新文件打不开时继续写旧文件,不丢日志
+		return err
+	}
+
+	oldFile.Close()
+	setActive(oldPath, false)
+	if w.opts.OnRotate != nil {
+		go w.opts.OnRotate(oldPath)
+	}
+	return nil
+}
+
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	day := w.opts.Now().Format("2006-01-02")
+	switch {
+	case day != w.day:
+		if err := w.rotate(day, lastSegment(w.dir, day)); err != nil {
+			LogWarning(os.Stdout, fmt.Sprintf("日志切分失败: %v", err))
+		}
+	case w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize:
+		if err := w.rotate(w.day, w.seq+1); err != nil {
+			LogWarning(os.Stdout, fmt.Sprintf("日志切分失败: %v", err))
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// SetMaxSize 修改切分大小,下一次写入时生效
+func (w *RotatingWriter) SetMaxSize(size int64) {
+	w.mu.Lock()
+	w.opts.MaxSize = size
+	w.mu.Unlock()
+}
+
+// ActivePath 当前正在写入的文件
+func (w *RotatingWriter) ActivePath() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.path
+}
+
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	setActive(w.path, false)
+	w.file = nil
+	return err
+}
+
+// activeFiles 正在被写入的日志文件,清理任务不会压缩或删除这些文件
+var (
+	activeFiles   = make(map[string]bool)
+	activeFilesMu sync.Mutex
+)
+
+func setActive(path string, active bool) {
+	activeFilesMu.Lock()
+	defer activeFilesMu.Unlock()
+
+	path = filepath.Clean(path)
+	if active {
+		activeFiles[path] = true
+	} else {
+		delete(activeFiles, path)
+	}
+}
+
+// IsActive 判断日志文件是否正在被写入
+func IsActive(path string) bool {
+	activeFilesMu.Lock()
+	defer activeFilesMu.Unlock()
+
+	return activeFiles[filepath.Clean(path)]
+}
@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readDir 目录中的文件名,按名称排序
+func readDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 14, 23, 59, 58, 0, time.Local)
+	rotated := make(chan string, 10)
+
+	w, err := NewRotatingWriter(dir, RotateOptions{
+		MaxSize:  20,
+		Now:      func() time.Time { return now },
+		OnRotate: func(path string) { rotated <- filepath.Base(path) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	write := func(line string) {
+		t.Helper()
+		if _, err := w.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 空文件中单次写入超过上限时不切分,避免产生空文件
+	write("first line is longer than max")
+	// 超过 max_file_size,切分为同一天的下一个分段
+	write("line 2")
+	write("line 3")
+	// 跨过午夜,按新日期切分,序号从0开始
+	now = now.Add(3 * time.Second)
+	write("after midnight")
+	write("line 5")
+
+	wantFiles := []string{"2026-03-14.1.log", "2026-03-14.log", "2026-03-15.1.log", "2026-03-15.log"}
+	if got := readDir(t, dir); strings.Join(got, ",") != strings.Join(wantFiles, ",") {
+		t.Errorf("文件 %v,期望 %v", got, wantFiles)
+	}
+	wantContent := map[string]string{
+		"2026-03-14.log":   "first line is longer than max\n",
+		"2026-03-14.1.log": "line 2\nline 3\n",
+		"2026-03-15.log":   "after midnight\n",
+		"2026-03-15.1.log": "line 5\n",
+	}
+	for name, want := range wantContent {
+		if got := readFile(t, filepath.Join(dir, name)); got != want {
+			t.Errorf("%s 的内容 %q,期望 %q", name, got, want)
+		}
+	}
+
+	// OnRotate 在单独的goroutine中调用,每个被切分出去的文件一次
+	var calls []string
+	for len(calls) < 3 {
+		select {
+		case name := <-rotated:
+			calls = append(calls, name)
+		case <-time.After(time.Second):
+			t.Fatalf("OnRotate 调用 %v,期望3次", calls)
+		}
+	}
+	sort.Strings(calls)
+	if want := "2026-03-14.1.log,2026-03-14.log,2026-03-15.log"; strings.Join(calls, ",") != want {
+		t.Errorf("OnRotate 调用 %v,期望 %s", calls, want)
+	}
+
+	active := filepath.Join(dir, "2026-03-15.1.log")
+	if w.ActivePath() != active || !IsActive(active) {
+		t.Errorf("当前文件 %s,期望 %s", w.ActivePath(), active)
+	}
+	if IsActive(filepath.Join(dir, "2026-03-15.log")) {
+		t.Error("已切分出去的文件不应标记为正在写入")
+	}
+
+	w.Close()
+	if IsActive(active) {
+		t.Error("关闭后文件不应标记为正在写入")
+	}
+	if _, err := w.Write([]byte("x\n")); err != os.ErrClosed {
+		t.Errorf("关闭后写入返回 %v,期望 os.ErrClosed", err)
+	}
+}
+
+func TestRotatingWriterAfterCompressedSegment(t *testing.T) {
+	dir := t.TempDir()
+	// 当天最后一个分段已被压缩,重启后使用下一个序号,避免再次压缩时覆盖 .log.gz
+	for _, name := range []string{"2026-03-14.log.gz", "2026-03-14.1.log.gz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("gz"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := NewRotatingWriter(dir, RotateOptions{
+		Now: func() time.Time { return time.Date(2026, 3, 14, 12, 0, 0, 0, time.Local) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if got, want := filepath.Base(w.ActivePath()), "2026-03-14.2.log"; got != want {
+		t.Errorf("当前文件 %s,期望 %s", got, want)
+	}
+}
@@ -0,0 +1,469 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"netmonitor/pkg/event"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日志来源
+const (
+	SourceListener    = "listener"
+	SourceEstablished = "established"
+)
+
+// 事件方向,用于按方向搜索
+const (
+	DirectionOpened = "opened" // listener_opened, conn_opened
+	DirectionClosed = "closed" // listener_closed, conn_closed
+)
+
+// SearchDir 一个待搜索的日志目录
+type SearchDir struct {
+	Source string // listener, established
+	Dir    string
+}
+
+// SearchOptions 日志搜索条件,零值字段表示不限
+type SearchOptions struct {
+	Dirs      []SearchDir
+	From      time.Time
+	To        time.Time
+	PID       int32
+	Process   string // 进程名(不区分大小写的子串),也匹配 owner_changed 的原进程
+	Addr      string // 本地或远程地址: IP、CIDR、IP:端口 或 :端口
+	Direction string // opened, closed 或具体的事件类型(如 state_changed, alert)
+	Limit     int    // 最大条数(0表示不限)
+}
+
+// Entry 搜索到的一条日志。文本格式的日志行被解析为与JSON格式相同的字段
+type Entry struct {
+	Record
+	Source string `json:"source"`
+	File   string `json:"file"`
+	Line   string `json:"line,omitempty"` // 文本格式日志的原始行(不含颜色)
+
+	time time.Time
+}
+
+// Time 日志的时间
+func (e Entry) Time() time.Time {
+	return e.time
+}
+
+// Text 日志的可读文本,与文本格式日志中的一行一致
+func (e Entry) Text() string {
+	if e.Line != "" {
+		return e.Line
+	}
+	return fmt.Sprintf("[%s] %s", e.time.Local().Format("2006-01-02 15:04:05"), RecordMessage(e.Record))
+}
+
+// RecordMessage JSON日志记录的可读文本,与 EventMessage 一致
+func RecordMessage(r Record) string {
+	local := joinAddr(r.LocalIP, r.LocalPort)
+	remote := joinAddr(r.RemoteIP, r.RemotePort)
+
+	switch event.Kind(r.Event) {
+	case event.ListenerOpened:
+		return connectionMessage("LISTEN", r.Protocol, local, "", r.PID, r.ProcessName, true)
+	case event.ListenerClosed:
+		return connectionMessage("LISTEN", r.Protocol, local, "", r.PID, r.ProcessName, false)
+	case event.ListenerOwnerChanged:
+		return ownerChangeMessage(r.Protocol, local, r.OldPID, r.OldProcessName, r.PID, r.ProcessName)
+	case event.ConnOpened:
+		return connectionMessage("", r.Protocol, local, remote, r.PID, r.ProcessName, true)
+	case event.ConnClosed:
+		lifetime := (time.Duration(r.Lifetime * float64(time.Second))).Round(time.Second).String()
+		if r.Baseline {
+			lifetime = "≥" + lifetime
+		}
+		return connectionClosedMessage(r.Protocol, local, remote, r.PID, r.ProcessName, lifetime)
+	case event.StateChanged:
+		return stateChangeMessage(r.Protocol, local, remote, r.FromState, r.ToState, r.PID, r.ProcessName)
+	case event.Alert:
+		return "[WARN] " + r.Message
+	}
+	if r.Event == RecordCleanup {
+		return r.Message
+	}
+	return r.Event
+}
+
+// joinAddr 拼接IP和端口,IPv6地址带方括号
+func joinAddr(ip string, port uint16) string {
+	if ip == "" {
+		return ""
+	}
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		return netip.AddrPortFrom(addr, port).String()
+	}
+	return ip + ":" + strconv.Itoa(int(port))
+}
+
+// 文本日志行的格式,见 connectionMessage 等函数
+var (
+	linePattern        = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\] (.*)$`)
+	listenPattern      = regexp.MustCompile(`^\[([+-])\] LISTEN (\S+) (\S*) PID:(-?\d+) ?(.*)$`)
+	ownerChangePattern = regexp.MustCompile(`^\[~\] LISTEN (\S+) (\S*) PID:(-?\d+) ?(.*) → PID:(-?\d+) ?(.*)$`)
+	closedPattern      = regexp.MustCompile(`^\[-\] (\S+) (\S*) → (\S*) PID:(-?\d+) ?(.*?) 存活:(\S+)$`)
+	connPattern        = regexp.MustCompile(`^\[([+-])\] (\S+) (\S*) → (\S*) PID:(-?\d+) ?(.*)$`)
+	statePattern       = regexp.MustCompile(`^\[\*\] (\S+) (\S*) → (\S*) (\S+)→(\S+) PID:(-?\d+) ?(.*)$`)
+	warnPattern        = regexp.MustCompile(`^\[WARN\] (.*)$`)
+)
+
+// ParseLine 解析一行日志,支持文本格式和JSON格式;无法识别的行返回 false
+func ParseLine(line string) (Entry, bool) {
+	line = strings.TrimSpace(StripANSI(line))
+	if strings.HasPrefix(line, "{") {
+		return parseJSONLine(line)
+	}
+	return parseTextLine(line)
+}
+
+func parseJSONLine(line string) (Entry, bool) {
+	var e Entry
+	if err := json.Unmarshal([]byte(line), &e.Record); err != nil || e.Event == "" {
+		return e, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, e.Timestamp)
+	if err != nil {
+		return e, false
+	}
+	e.time = t
+	return e, true
+}
+
+func parseTextLine(line string) (Entry, bool) {
+	m := linePattern.FindStringSubmatch(line)
+	if m == nil {
+		return Entry{}, false
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", m[1], time.Local)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	e := Entry{Line: line, time: t}
+	e.Timestamp = t.Format(time.RFC3339Nano)
+	r := &e.Record
+	msg := m[2]
+
+	if m := listenPattern.FindStringSubmatch(msg); m != nil {
+		r.Event = string(event.ListenerClosed)
+		if m[1] == "+" {
+			r.Event = string(event.ListenerOpened)
+		}
+		r.Protocol = m[2]
+		r.LocalIP, r.LocalPort = splitAddr(m[3])
+		r.PID = parsePID(m[4])
+		r.ProcessName = m[5]
+	} else if m := ownerChangePattern.FindStringSubmatch(msg); m != nil {
+		r.Event = string(event.ListenerOwnerChanged)
+		r.Protocol = m[1]
+		r.LocalIP, r.LocalPort = splitAddr(m[2])
+		r.OldPID = parsePID(m[3])
+		r.OldProcessName = m[4]
+		r.PID = parsePID(m[5])
+		r.ProcessName = m[6]
+	} else if m := closedPattern.FindStringSubmatch(msg); m != nil {
+		r.Event = string(event.ConnClosed)
+		r.Protocol = m[1]
+		r.LocalIP, r.LocalPort = splitAddr(m[2])
+		r.RemoteIP, r.RemotePort = splitAddr(m[3])
+		r.PID = parsePID(m[4])
+		r.ProcessName = m[5]
+		lifetime := m[6]
+		if strings.HasPrefix(lifetime, "≥") {
+			r.Baseline = true
+			lifetime = strings.TrimPrefix(lifetime, "≥")
+		}
+		if d, err := time.ParseDuration(lifetime); err == nil {
+			r.Lifetime = d.Seconds()
+		}
+	} else if m := connPattern.FindStringSubmatch(msg); m != nil {
+		r.Event = string(event.ConnClosed)
+		if m[1] == "+" {
+			r.Event = string(event.ConnOpened)
+		}
+		r.Protocol = m[2]
+		r.LocalIP, r.LocalPort = splitAddr(m[3])
+		r.RemoteIP, r.RemotePort = splitAddr(m[4])
+		r.PID = parsePID(m[5])
+		r.ProcessName = m[6]
+	} else if m := statePattern.FindStringSubmatch(msg); m != nil {
+		r.Event = string(event.StateChanged)
+		r.Protocol = m[1]
+		r.LocalIP, r.LocalPort = splitAddr(m[2])
+		r.RemoteIP, r.RemotePort = splitAddr(m[3])
+		r.FromState = m[4]
+		r.ToState = m[5]
+		r.Status = m[5]
+		r.PID = parsePID(m[6])
+		r.ProcessName = m[7]
+	} else if m := warnPattern.FindStringSubmatch(msg); m != nil {
+		r.Event = string(event.Alert)
+		r.Message = m[1]
+	} else {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// splitAddr 拆分日志中的 IP:端口,IPv6地址带方括号
+func splitAddr(s string) (string, uint16) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().String(), ap.Port()
+	}
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return s, 0
+	}
+	port, _ := strconv.ParseUint(s[i+1:], 10, 16)
+	return strings.Trim(s[:i], "[]"), uint16(port)
+}
+
+func parsePID(s string) int32 {
+	n, _ := strconv.ParseInt(s, 10, 32)
+	return int32(n)
+}
+
+// addrMatcher 地址条件: IP或网段,加上可选的端口
+type addrMatcher struct {
+	prefix netip.Prefix // 无效表示不限IP
+	port   uint16       // 0表示不限端口
+}
+
+// parseAddrMatcher 解析地址条件: 1.2.3.4、10.0.0.0/8、1.2.3.4:80、[::1]:80、:80
+func parseAddrMatcher(s string) (*addrMatcher, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(s, ":") {
+		port, err := strconv.ParseUint(s[1:], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("无效的端口: %s", s)
+		}
+		return &addrMatcher{port: uint16(port)}, nil
+	}
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的地址范围: %s", s)
+		}
+		return &addrMatcher{prefix: prefix.Masked()}, nil
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		addr := ap.Addr().Unmap()
+		return &addrMatcher{prefix: netip.PrefixFrom(addr, addr.BitLen()), port: ap.Port()}, nil
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return nil, fmt.Errorf("无效的地址: %s", s)
+	}
+	addr = addr.Unmap()
+	return &addrMatcher{prefix: netip.PrefixFrom(addr, addr.BitLen())}, nil
+}
+
+func (m *addrMatcher) match(ip string, port uint16) bool {
+	if ip == "" {
+		return false
+	}
+	if m.port != 0 && port != m.port {
+		return false
+	}
+	if !m.prefix.IsValid() {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && m.prefix.Contains(addr.Unmap())
+}
+
+// searcher 编译后的搜索条件
+type searcher struct {
+	opts SearchOptions
+	addr *addrMatcher
+}
+
+func (s *searcher) match(e Entry) bool {
+	o := &s.opts
+	if !o.From.IsZero() && e.time.Before(o.From) {
+		return false
+	}
+	if !o.To.IsZero() && e.time.After(o.To) {
+		return false
+	}
+	if o.PID != 0 && e.PID != o.PID && e.OldPID != o.PID {
+		return false
+	}
+	if o.Process != "" {
+		process := strings.ToLower(o.Process)
+		if !strings.Contains(strings.ToLower(e.ProcessName), process) &&
+			!strings.Contains(strings.ToLower(e.OldProcessName), process) {
+			return false
+		}
+	}
+	if s.addr != nil && !s.addr.match(e.LocalIP, e.LocalPort) && !s.addr.match(e.RemoteIP, e.RemotePort) {
+		return false
+	}
+	switch strings.ToLower(o.Direction) {
+	case "":
+	case DirectionOpened:
+		return e.Event == string(event.ListenerOpened) || e.Event == string(event.ConnOpened)
+	case DirectionClosed:
+		return e.Event == string(event.ListenerClosed) || e.Event == string(event.ConnClosed)
+	default:
+		return strings.EqualFold(e.Event, o.Direction)
+	}
+	return true
+}
+
+// searchFile 日期范围内的一个日志文件
+type searchFile struct {
+	logFile
+	source string
+}
+
+// Search 按时间顺序搜索日志目录中的文本和JSON日志(包括已压缩的 .log.gz),
+// 对每条满足条件的日志调用 fn,fn 返回false或达到 Limit 时停止
+func Search(opts SearchOptions, fn func(Entry) bool) error {
+	addr, err := parseAddrMatcher(opts.Addr)
+	if err != nil {
+		return err
+	}
+	s := &searcher{opts: opts, addr: addr}
+
+	files, err := searchFiles(opts)
+	if err != nil {
+		return err
+	}
+
+	// 按天读取: 同一天的日志来自不同目录和分段,合并后按时间排序
+	count := 0
+	for len(files) > 0 {
+		n := 1
+		for n < len(files) && files[n].date.Equal(files[0].date) {
+			n++
+		}
+
+		var entries []Entry
+		for _, f := range files[:n] {
+			err := readLogFile(f.path, func(e Entry) {
+				if s.match(e) {
+					e.Source = f.source
+					e.File = f.path
+					entries = append(entries, e)
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].time.Before(entries[j].time) })
+
+		for _, e := range entries {
+			if !fn(e) {
+				return nil
+			}
+			count++
+			if opts.Limit > 0 && count >= opts.Limit {
+				return nil
+			}
+		}
+		files = files[n:]
+	}
+	return nil
+}
+
+// searchFiles 列出日期范围内的日志文件,按日期、来源和分段序号排序
+func searchFiles(opts SearchOptions) ([]searchFile, error) {
+	var files []searchFile
+	for _, d := range opts.Dirs {
+		list, err := listLogFiles(d.Dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		plain := make(map[string]bool)
+		for _, f := range list {
+			if !f.compressed {
+				plain[f.path] = true
+			}
+		}
+		for _, f := range list {
+			// 压缩过程中原文件和 .gz 可能同时存在,以原文件为准
+			if f.compressed && plain[strings.TrimSuffix(f.path, ".gz")] {
+				continue
+			}
+			if !opts.To.IsZero() && f.date.After(opts.To) {
+				continue
+			}
+			if !opts.From.IsZero() && !f.date.AddDate(0, 0, 1).After(opts.From) {
+				continue
+			}
+			files = append(files, searchFile{logFile: f, source: d.Source})
+		}
+	}
+
+	order := make(map[string]int)
+	for i, d := range opts.Dirs {
+		order[d.Source] = i
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if !a.date.Equal(b.date) {
+			return a.date.Before(b.date)
+		}
+		if a.source != b.source {
+			return order[a.source] < order[b.source]
+		}
+		return a.seq < b.seq
+	})
+	return files, nil
+}
+
+// readLogFile 逐行读取日志文件,.gz 文件先解压;无法识别的行被跳过
+func readLogFile(path string, fn func(Entry)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开日志失败: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("解压日志 %s 失败: %w", filepath.Base(path), err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if e, ok := ParseLine(scanner.Text()); ok {
+			fn(e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取日志 %s 失败: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"netmonitor/pkg/event"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Syslog 启用syslog输出时的全局发送端,LogWarning 会同时写入该发送端。
+// 需要在启动任何会写日志的goroutine之前设置
+var Syslog *SyslogSink
+
+// syslog传输方式
+const (
+	SyslogUnix = "unixgram" // 本机syslog守护进程的数据报套接字
+	SyslogUDP  = "udp"
+	SyslogTCP  = "tcp" // 按RFC 6587使用八位组计数分帧
+)
+
+// syslog严重级别
+const (
+	SeverityEmergency = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSDID 结构化数据ID,32473为IANA保留给文档示例的企业号
+const syslogSDID = "conn@32473"
+
+const (
+	syslogQueueSize = 1024            // 等待发送的消息数上限,超出时丢弃新消息
+	syslogTimeout   = 5 * time.Second // 连接、单次写入以及关闭时等待队列发送完的超时
+)
+
+// SyslogOptions syslog输出配置
+type SyslogOptions struct {
+	Network  string // unixgram, udp, tcp
+	Address  string // 留空时: unixgram为/dev/log, udp/tcp为localhost:514
+	Facility string // 留空时为 daemon
+	AppName  string // 留空时为 netmonitor
+}
+
+// SyslogSink 以RFC 5424格式发送事件到syslog。消息先放入有界队列,由后台goroutine发送,
+// 调用方(监控循环、持有日志文件锁的写入)不会因syslog服务器缓慢或不可达而阻塞
+type SyslogSink struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	procID   string
+	conn     net.Conn // 只由后台goroutine使用
+
+	queue   chan string
+	done    chan struct{}
+	dropped atomic.Int64 // 队列已满时丢弃的消息数,下次发送时报告
+	closed  bool
+	mu      sync.RWMutex // 保护 closed,避免向已关闭的队列发送
+}
+
+func NewSyslogSink(opts SyslogOptions) (*SyslogSink, error) {
+	network := strings.ToLower(opts.Network)
+	address := opts.Address
+	switch network {
+	case "", SyslogUnix, "unix":
+		network = SyslogUnix
+		if address == "" {
+			address = "/dev/log"
+		}
+	case SyslogUDP, SyslogTCP:
+		if address == "" {
+			address = "localhost:514"
+		}
+	default:
+		return nil, fmt.Errorf("未知的syslog传输方式: %s", opts.Network)
+	}
+
+	facility := "daemon"
+	if opts.Facility != "" {
+		facility = strings.ToLower(opts.Facility)
+	}
+	code, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("未知的syslog facility: %s", opts.Facility)
+	}
+
+	appName := opts.AppName
+	if appName == "" {
+		appName = "netmonitor"
+	}
+	hostname, _ := os.Hostname()
+
+	s := &SyslogSink{
+		network:  network,
+		address:  address,
+		facility: code,
+		appName:  headerField(appName, 48),
+		hostname: headerField(hostname, 255),
+		procID:   strconv.Itoa(os.Getpid()),
+		queue:    make(chan string, syslogQueueSize),
+		done:     make(chan struct{}),
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *SyslogSink) connect() error {
+	conn, err := net.DialTimeout(s.network, s.address, syslogTimeout)
+	if err != nil {
+		return fmt.Errorf("连接syslog失败: %w", err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// eventSeverity 事件对应的严重级别
+func eventSeverity(kind event.Kind) int {
+	switch kind {
+	case event.Alert:
+		return SeverityWarning
+	case event.ListenerOpened, event.ListenerOwnerChanged:
+		return SeverityNotice
+	default:
+		return SeverityInfo
+	}
+}
+
+// HandleEvent 将事件发送到syslog,作为事件总线的订阅者使用
+func (s *SyslogSink) HandleEvent(e event.Event) {
+	s.send(eventSeverity(e.Kind), e.Timestamp, string(e.Kind), eventStructuredData(e), EventMessage(e))
+}
+
+// Warning 发送警告消息
+func (s *SyslogSink) Warning(message string) {
+	s.send(SeverityWarning, time.Now(), "warning", "-", message)
+}
+
+// Info 发送普通消息
+func (s *SyslogSink) Info(message string) {
+	s.send(SeverityInfo, time.Now(), "info", "-", message)
+}
+
+// Close 停止接收新消息,等待队列中的消息发送完后关闭连接;超时后剩余的消息被丢弃
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(syslogTimeout):
+		return fmt.Errorf("等待syslog发送超时,丢弃 %d 条消息", len(s.queue))
+	}
+}
+
+// Format 生成一条RFC 5424消息:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA BOM MSG
+func (s *SyslogSink) Format(severity int, ts time.Time, msgID, sd, message string) string {
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s %s \ufeff%s",
+		s.facility*8+severity, ts.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, s.appName, s.procID, headerField(msgID, 32), sd, message)
+}
+
+// send 将消息放入发送队列,不等待发送;队列已满或已关闭时丢弃
+func (s *SyslogSink) send(severity int, ts time.Time, msgID, sd, message string) {
+	msg := s.Format(severity, ts, msgID, sd, message)
+	if s.network == SyslogTCP {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- msg:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run 后台发送队列中的消息,队列关闭后关闭连接
+func (s *SyslogSink) run() {
+	defer close(s.done)
+	for msg := range s.queue {
+		if n := s.dropped.Swap(0); n > 0 {
+			logWarning(os.Stdout, fmt.Sprintf("syslog发送队列已满,丢弃了 %d 条消息", n))
+		}
+		s.write(msg)
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *SyslogSink) write(msg string) {
+	// 发送失败时重连一次,仍然失败则丢弃该消息
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err := s.connect(); err != nil {
+				logWarning(os.Stdout, err.Error())
+				return
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err := s.conn.Write([]byte(msg)); err == nil {
+			return
+		} else if attempt > 0 {
+			logWarning(os.Stdout, fmt.Sprintf("发送syslog失败: %v", err))
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// eventStructuredData 事件的结构化数据: 协议、地址、进程以及事件相关的元数据
+func eventStructuredData(e event.Event) string {
+	c := e.Conn
+	var params []string
+	add := func(name, value string) {
+		if value != "" {
+			params = append(params, fmt.Sprintf(`%s="%s"`, name, sdEscape(value)))
+		}
+	}
+
+	add("proto", c.Protocol)
+	add("family", c.Family)
+	add("status", c.Status)
+	if c.LocalAddr.IsValid() {
+		add("local_ip", c.LocalAddr.Addr().String())
+		add("local_port", strconv.Itoa(int(c.LocalAddr.Port())))
+	}
+	if c.RemoteAddr.IsValid() {
+		add("remote_ip", c.RemoteAddr.Addr().String())
+		add("remote_port", strconv.Itoa(int(c.RemoteAddr.Port())))
+	}
+	if c.PID > 0 {
+		add("pid", strconv.Itoa(int(c.PID)))
+	}
+	add("process", c.ProcessName)
+	for _, key := range []string{event.MetaLifetime, event.MetaBaseline, event.MetaOldPID, event.MetaOldProcessName,
+		event.MetaFromState, event.MetaToState, event.MetaAlertKind, event.MetaCount} {
+		add(key, e.Meta(key))
+	}
+
+	if len(params) == 0 {
+		return "-"
+	}
+	return "[" + syslogSDID + " " + strings.Join(params, " ") + "]"
+}
+
+// sdEscape 转义结构化数据参数值中的 " \ ]
+func sdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// headerField 头部字段只允许可打印ASCII字符且有长度限制,空值用 "-" 表示
+func headerField(s string, maxLen int) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+	}
+	field := b.String()
+	if field == "" {
+		return "-"
+	}
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	return field
+}
@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/netip"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/netinfo"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syslogTestEvent 进程名中包含结构化数据需要转义的字符
+func syslogTestEvent() event.Event {
+	return event.New(event.ListenerOpened, time.Date(2026, 3, 14, 12, 0, 0, 123456000, time.UTC), netinfo.Connection{
+		Protocol: "TCP", Family: netinfo.FamilyIPv4, Status: "LISTEN", PID: 100, ProcessName: `a"b\c]d`,
+		LocalAddr:  netip.MustParseAddrPort("127.0.0.1:8080"),
+		RemoteAddr: netip.MustParseAddrPort("0.0.0.0:0"),
+	})
+}
+
+// checkSyslogMessage 检查RFC 5424头部和结构化数据。local3(19)*8 + notice(5) = 157,
+// APP-NAME 中的空格被去掉
+func checkSyslogMessage(t *testing.T, msg string) {
+	t.Helper()
+	header := regexp.MustCompile(`^<157>1 2026-03-14T12:00:00\.123456Z \S+ nettest ` +
+		strconv.Itoa(os.Getpid()) + ` listener_opened \[conn@32473 `)
+	if !header.MatchString(msg) {
+		t.Errorf("消息头部不符合RFC 5424: %q", msg)
+	}
+	for _, want := range []string{
+		`proto="TCP"`, `local_ip="127.0.0.1"`, `local_port="8080"`, `pid="100"`,
+		`process="a\"b\\c\]d"] ` + "\ufeff",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("消息 %q 不包含 %q", msg, want)
+		}
+	}
+}
+
+func newTestSyslogSink(t *testing.T, network, address string) *SyslogSink {
+	t.Helper()
+	s, err := NewSyslogSink(SyslogOptions{Network: network, Address: address, Facility: "local3", AppName: "net test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSyslogDatagram(t *testing.T) {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	unixPath := filepath.Join(t.TempDir(), "log.sock")
+	unix, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: unixPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close()
+
+	tests := []struct {
+		network string
+		address string
+		conn    net.PacketConn
+	}{
+		{SyslogUDP, udp.LocalAddr().String(), udp},
+		{SyslogUnix, unixPath, unix},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			s := newTestSyslogSink(t, tt.network, tt.address)
+			s.HandleEvent(syslogTestEvent())
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// 数据报不分帧,每个数据报是一条消息
+			buf := make([]byte, 4096)
+			tt.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := tt.conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSyslogMessage(t, string(buf[:n]))
+		})
+	}
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s := newTestSyslogSink(t, SyslogTCP, ln.Addr().String())
+	s.HandleEvent(syslogTestEvent())
+	s.Warning("第二条\n消息")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// RFC 6587 八位组计数: MSG-LEN SP SYSLOG-MSG,消息本身可以包含换行
+	r := bufio.NewReader(conn)
+	var msgs []string
+	for i := 0; i < 2; i++ {
+		prefix, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil {
+			t.Fatalf("无效的长度前缀 %q", prefix)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, string(buf))
+	}
+
+	checkSyslogMessage(t, msgs[0])
+	// warning(4): 19*8 + 4 = 156,没有结构化数据
+	if !strings.HasPrefix(msgs[1], "<156>1 ") || !strings.HasSuffix(msgs[1], " warning - \ufeff第二条\n消息") {
+		t.Errorf("第二条消息 %q", msgs[1])
+	}
+	if rest, _ := r.ReadString(0); rest != "" {
+		t.Errorf("多余的数据 %q", rest)
+	}
+}
+
+func TestSyslogSendAfterClose(t *testing.T) {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	s := newTestSyslogSink(t, SyslogUDP, udp.LocalAddr().String())
+	s.Close()
+	// 关闭后发送直接丢弃,不阻塞也不panic
+	s.Warning("丢弃")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
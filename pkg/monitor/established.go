@@ -2,18 +2,41 @@ package monitor
 
 import (
 	"fmt"
-	"netmonitor/pkg/logger"
 	"netmonitor/pkg/netinfo"
+	"time"
 )
 
 type EstablishedMonitor struct {
-	initialState map[string]netinfo.Connection
+	initialState map[string]TrackedConnection // 全部已建立连接,包括被过滤的,过滤条件变化时可以重新判断
 	filter       *netinfo.ConnectionFilter
 }
 
+// TrackedConnection 带生命周期信息的连接
+type TrackedConnection struct {
+	netinfo.Connection
+	FirstSeen time.Time // 首次出现在快照中的时间
+	LastSeen  time.Time // 最后一次出现在快照中的时间
+	Baseline  bool      // 启动时已存在,实际建立时间早于 FirstSeen
+	reported  bool      // 通过了过滤器,新建和关闭都会报告
+}
+
+// Lifetime 观测到的存活时长
+func (t TrackedConnection) Lifetime() time.Duration {
+	return t.LastSeen.Sub(t.FirstSeen)
+}
+
+// LifetimeString 存活时长的可读形式,启动前已存在的连接只能给出下限
+func (t TrackedConnection) LifetimeString() string {
+	d := t.Lifetime().Round(time.Second)
+	if t.Baseline {
+		return "≥" + d.String()
+	}
+	return d.String()
+}
+
 func NewEstablishedMonitor(filter *netinfo.ConnectionFilter) *EstablishedMonitor {
 	return &EstablishedMonitor{
-		initialState: make(map[string]netinfo.Connection),
+		initialState: make(map[string]TrackedConnection),
 		filter:       filter,
 	}
 }
@@ -22,66 +45,62 @@ func (m *EstablishedMonitor) getKey(c netinfo.Connection) string {
 	return fmt.Sprintf("%s|%s|%s", c.Protocol, c.LocalAddr, c.RemoteAddr)
 }
 
-func (m *EstablishedMonitor) Initialize() error {
-	conns, err := netinfo.GetConnections()
-	if err != nil {
-		return err
-	}
-
-	for _, c := range conns {
-		if c.Status == "ESTABLISHED" && !m.filter.ShouldFilter(c) {
-			m.initialState[m.getKey(c)] = c
+// SetBaseline 以给定快照作为基线
+func (m *EstablishedMonitor) SetBaseline(snap *netinfo.Snapshot) {
+	for _, c := range snap.Connections {
+		if c.Status == "ESTABLISHED" {
+			m.initialState[m.getKey(c)] = TrackedConnection{
+				Connection: c,
+				FirstSeen:  snap.Timestamp,
+				LastSeen:   snap.Timestamp,
+				Baseline:   true,
+				reported:   !m.filter.ShouldFilter(c),
+			}
 		}
 	}
-	return nil
 }
 
-func (m *EstablishedMonitor) CheckChanges() ([]netinfo.Connection, []netinfo.Connection, error) {
-	currentConns, err := netinfo.GetConnections()
-	if err != nil {
-		return nil, nil, err
-	}
-
+// CheckChanges 对比快照与上一次状态,返回新建的连接以及带存活时长的关闭连接。
+// 连接是否报告在首次出现时由过滤器决定,被过滤的连接新建和关闭都不报告
+func (m *EstablishedMonitor) CheckChanges(snap *netinfo.Snapshot) ([]netinfo.Connection, []TrackedConnection) {
 	var newConnections []netinfo.Connection
-	var closedConnections []netinfo.Connection
-	currentState := make(map[string]netinfo.Connection)
+	var closedConnections []TrackedConnection
+	currentState := make(map[string]TrackedConnection)
 
-	for _, c := range currentConns {
+	for _, c := range snap.Connections {
 		if c.Status == "ESTABLISHED" {
 			key := m.getKey(c)
-			currentState[key] = c
-
-			// 检查是否被过滤器过滤
-			shouldFilter := m.filter.ShouldFilter(c)
 
-			// 检查新连接
-			if _, exists := m.initialState[key]; !exists && !shouldFilter {
-				newConnections = append(newConnections, c)
+			// 检查新连接,被过滤器过滤的连接同样跟踪,但不报告
+			tracked, exists := m.initialState[key]
+			if !exists {
+				tracked = TrackedConnection{FirstSeen: snap.Timestamp, reported: !m.filter.ShouldFilter(c)}
+				if tracked.reported {
+					newConnections = append(newConnections, c)
+				}
 			}
+			tracked.Connection = c
+			tracked.LastSeen = snap.Timestamp
+			currentState[key] = tracked
 		}
 	}
 
 	// 检查关闭的连接
 	for key, oldConn := range m.initialState {
-		if _, exists := currentState[key]; !exists {
+		if _, exists := currentState[key]; !exists && oldConn.reported {
 			closedConnections = append(closedConnections, oldConn)
 		}
 	}
 
 	m.initialState = currentState
-	return newConnections, closedConnections, nil
+	return newConnections, closedConnections
 }
 
-func (m *EstablishedMonitor) LogNewConnections(conns []netinfo.Connection) {
-	for _, c := range conns {
-		logger.LogConnection(logger.EstablishedWriter, "", c.Protocol,
-			c.LocalAddr, c.RemoteAddr, c.PID, c.ProcessName, true)
+// refilter 过滤条件变化后重新判断已跟踪的连接是否报告,不产生事件。
+// 新变为可见的连接保留首次出现的时间,关闭时给出准确的存活时长
+func (m *EstablishedMonitor) refilter() {
+	for key, t := range m.initialState {
+		t.reported = !m.filter.ShouldFilter(t.Connection)
+		m.initialState[key] = t
 	}
 }
-
-func (m *EstablishedMonitor) LogClosedConnections(conns []netinfo.Connection) {
-	for _, c := range conns {
-		logger.LogConnection(logger.EstablishedWriter, "", c.Protocol,
-			c.LocalAddr, c.RemoteAddr, c.PID, c.ProcessName, false)
-	}
-}
\ No newline at end of file
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/netinfo"
+	"strconv"
+	"time"
+)
+
+// Dispatcher 每轮用同一份快照驱动各监控器,并把检测结果作为事件发布到总线
+type Dispatcher struct {
+	Listener    *ListenerMonitor
+	Established *EstablishedMonitor
+	State       *StateMonitor
+	TrackStates bool // 是否发布 state_changed 事件,告警不受影响
+	bus         *event.Bus
+}
+
+func NewDispatcher(bus *event.Bus, listener *ListenerMonitor, established *EstablishedMonitor, state *StateMonitor) *Dispatcher {
+	return &Dispatcher{
+		Listener:    listener,
+		Established: established,
+		State:       state,
+		bus:         bus,
+	}
+}
+
+// SetBaseline 以给定快照作为所有监控器的共同基线
+func (d *Dispatcher) SetBaseline(snap *netinfo.Snapshot) {
+	d.Listener.SetBaseline(snap)
+	d.Established.SetBaseline(snap)
+	d.State.SetBaseline(snap)
+}
+
+// SetFilter 替换所有监控器使用的过滤器,并按新的过滤条件重新判断已跟踪的连接和监听端口。
+// 切换过滤条件本身不产生事件,之后的新建和关闭都按新的过滤条件报告。
+// 原地修改了过滤器之后也需要调用。需要与 Process 在同一goroutine中调用
+func (d *Dispatcher) SetFilter(filter *netinfo.ConnectionFilter) {
+	d.Listener.filter = filter
+	d.Listener.refilter()
+	d.Established.filter = filter
+	d.Established.refilter()
+	d.State.filter = filter
+}
+
+// Process 检测快照中的变化并发布事件,返回本轮发布的事件数
+func (d *Dispatcher) Process(snap *netinfo.Snapshot) int {
+	events := d.Detect(snap)
+	for _, e := range events {
+		d.bus.Publish(e)
+	}
+	return len(events)
+}
+
+// Detect 检测快照中的变化并返回事件,不发布到总线
+func (d *Dispatcher) Detect(snap *netinfo.Snapshot) []event.Event {
+	opened, closed, changes := d.Listener.CheckChanges(snap)
+	events := ListenerEvents(snap.Timestamp, opened, closed, changes)
+
+	newEstablished, closedEstablished := d.Established.CheckChanges(snap)
+	events = append(events, ConnectionEvents(snap.Timestamp, newEstablished, closedEstablished)...)
+
+	transitions, alerts := d.State.CheckChanges(snap)
+	if d.TrackStates {
+		events = append(events, TransitionEvents(snap.Timestamp, transitions)...)
+	}
+	return append(events, AlertEvents(snap.Timestamp, alerts)...)
+}
+
+// ListenerEvents 将监听端口的检测结果转换为事件
+func ListenerEvents(ts time.Time, opened, closed []netinfo.Connection, changes []ListenerOwnerChange) []event.Event {
+	var events []event.Event
+	for _, c := range opened {
+		events = append(events, event.New(event.ListenerOpened, ts, c))
+	}
+	for _, c := range closed {
+		events = append(events, event.New(event.ListenerClosed, ts, c))
+	}
+	for _, ch := range changes {
+		events = append(events, event.New(event.ListenerOwnerChanged, ts, ch.New).
+			With(event.MetaOldPID, strconv.Itoa(int(ch.Old.PID))).
+			With(event.MetaOldProcessName, ch.Old.ProcessName))
+	}
+	return events
+}
+
+// ConnectionEvents 将已建立连接的检测结果转换为事件,关闭事件附带存活时长
+func ConnectionEvents(ts time.Time, opened []netinfo.Connection, closed []TrackedConnection) []event.Event {
+	var events []event.Event
+	for _, c := range opened {
+		events = append(events, event.New(event.ConnOpened, ts, c))
+	}
+	for _, c := range closed {
+		events = append(events, event.New(event.ConnClosed, ts, c.Connection).
+			With(event.MetaLifetime, c.Lifetime().String()).
+			With(event.MetaBaseline, strconv.FormatBool(c.Baseline)))
+	}
+	return events
+}
+
+// TransitionEvents 将TCP状态变化转换为事件
+func TransitionEvents(ts time.Time, transitions []StateTransition) []event.Event {
+	var events []event.Event
+	for _, t := range transitions {
+		events = append(events, event.New(event.StateChanged, ts, t.Conn).
+			With(event.MetaFromState, t.From).
+			With(event.MetaToState, t.To))
+	}
+	return events
+}
+
+// AlertEvents 将告警转换为事件,连接中只有PID和进程名有效
+func AlertEvents(ts time.Time, alerts []Alert) []event.Event {
+	var events []event.Event
+	for _, a := range alerts {
+		conn := netinfo.Connection{PID: a.PID, ProcessName: a.ProcessName}
+		events = append(events, event.New(event.Alert, ts, conn).
+			With(event.MetaAlertKind, a.Kind).
+			With(event.MetaCount, strconv.Itoa(a.Count)).
+			With(event.MetaMessage, a.Message))
+	}
+	return events
+}
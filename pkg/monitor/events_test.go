@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"fmt"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/netinfo"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// describeEvent 事件的简短描述: 类型 协议 本地地址 远程地址 PID 进程名 以及按键排序的元数据
+func describeEvent(e event.Event) string {
+	c := e.Conn
+	parts := []string{string(e.Kind)}
+	if c.Protocol != "" {
+		parts = append(parts, c.Protocol, netinfo.FormatAddr(c.LocalAddr), netinfo.FormatAddr(c.RemoteAddr))
+	}
+	parts = append(parts, fmt.Sprintf("%d/%s", c.PID, c.ProcessName))
+
+	keys := make([]string, 0, len(e.Metadata))
+	for k := range e.Metadata {
+		if k != event.MetaMessage {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, k+"="+e.Metadata[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// replayEvents 回放 testdata/replay.jsonl: 第一份快照作为基线,之后每份快照检测一轮,
+// 返回每轮的事件描述(排序后,监控器内部按map遍历,顺序不固定)
+func replayEvents(t *testing.T, filter *netinfo.ConnectionFilter) [][]string {
+	t.Helper()
+	collector, err := netinfo.LoadReplay(filepath.Join("testdata", "replay.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := collector.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(nil, NewListenerMonitor(filter), NewEstablishedMonitor(filter), NewStateMonitor(filter, 2))
+	d.TrackStates = true
+	d.SetBaseline(baseline)
+
+	var rounds [][]string
+	for collector.Remaining() > 0 {
+		snap, err := collector.Collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var round []string
+		for _, e := range d.Detect(snap) {
+			round = append(round, describeEvent(e))
+		}
+		sort.Strings(round)
+		rounds = append(rounds, round)
+	}
+	return rounds
+}
+
+func checkRounds(t *testing.T, got, want [][]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("检测了 %d 轮,期望 %d 轮", len(got), len(want))
+	}
+	for i := range want {
+		if strings.Join(got[i], "\n") != strings.Join(want[i], "\n") {
+			t.Errorf("第%d轮事件\n%s\n期望\n%s", i+1, strings.Join(got[i], "\n"), strings.Join(want[i], "\n"))
+		}
+	}
+}
+
+func TestDispatcherReplay(t *testing.T) {
+	got := replayEvents(t, &netinfo.ConnectionFilter{})
+	want := [][]string{
+		{
+			// 已建立的连接进入CLOSE_WAIT: 关闭事件(启动前已存在)、状态变化,
+			// 加上新出现的CLOSE_WAIT套接字,curl 达到阈值2触发告警
+			"alert 400/curl alert_kind=close_wait count=2",
+			"conn_closed TCP 10.0.0.5:40000 198.51.100.1:443 400/curl baseline=true lifetime=0s",
+			"conn_opened TCP 10.0.0.5:80 203.0.113.8:52000 100/nginx",
+			"listener_closed UDP 127.0.0.53:53 0.0.0.0:0 300/systemd-resolved",
+			"listener_opened TCP 127.0.0.1:6379 0.0.0.0:0 500/redis-server",
+			"listener_owner_changed TCP [::]:22 [::]:0 201/sshd old_pid=200 old_process_name=sshd",
+			"state_changed TCP 10.0.0.5:40000 198.51.100.1:443 400/curl from_state=ESTABLISHED to_state=CLOSE_WAIT",
+		},
+		// CLOSE_WAIT套接字消失不产生事件,告警状态复位
+		nil,
+		{
+			"conn_closed TCP 10.0.0.5:80 203.0.113.7:51000 100/nginx baseline=true lifetime=30s",
+			"conn_closed TCP 10.0.0.5:80 203.0.113.8:52000 100/nginx baseline=false lifetime=20s",
+		},
+	}
+	checkRounds(t, got, want)
+}
+
+func TestDispatcherReplayFiltered(t *testing.T) {
+	// 被过滤的连接同样跟踪,但新建、关闭、状态变化和告警都不报告
+	got := replayEvents(t, &netinfo.ConnectionFilter{PIDs: []int32{300, 500}})
+	want := [][]string{
+		{
+			"listener_closed UDP 127.0.0.53:53 0.0.0.0:0 300/systemd-resolved",
+			"listener_opened TCP 127.0.0.1:6379 0.0.0.0:0 500/redis-server",
+		},
+		nil,
+		nil,
+	}
+	checkRounds(t, got, want)
+}
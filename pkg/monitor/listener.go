@@ -1,106 +1,139 @@
 package monitor
 
 import (
-	"net"
-	"netmonitor/pkg/logger"
+	"fmt"
 	"netmonitor/pkg/netinfo"
-	"strconv"
 )
 
 type ListenerMonitor struct {
-	initialState map[uint32]netinfo.Connection
+	initialState map[string]trackedListener // 全部监听端点,包括被过滤的,过滤条件变化时可以重新判断
 	filter       *netinfo.ConnectionFilter
 }
 
+// trackedListener 监听端点以及它是否通过了过滤器
+type trackedListener struct {
+	netinfo.Connection
+	reported bool // 新增和关闭都会报告
+}
+
+// ListenerOwnerChange 同一监听端点被另一个进程重新绑定
+type ListenerOwnerChange struct {
+	Old netinfo.Connection // 原持有者
+	New netinfo.Connection // 新持有者
+}
+
 func NewListenerMonitor(filter *netinfo.ConnectionFilter) *ListenerMonitor {
 	return &ListenerMonitor{
-		initialState: make(map[uint32]netinfo.Connection),
+		initialState: make(map[string]trackedListener),
 		filter:       filter,
 	}
 }
 
-// 从地址字符串提取端口号
-func extractPort(addr string) uint32 {
-	_, portStr, err := net.SplitHostPort(addr)
-	if err != nil {
-		return 0
-	}
-	port, _ := strconv.ParseUint(portStr, 10, 32)
-	return uint32(port)
+// listenerKey 监听端点标识: 协议+地址族+绑定地址+端口
+func listenerKey(c netinfo.Connection) string {
+	return fmt.Sprintf("%s|%s|%s", c.Protocol, c.Family, c.LocalAddr)
 }
 
-// 判断是否为监听端口（完全对齐Python逻辑）
-func isListeningPort(c netinfo.Connection) bool {
+// IsListeningPort 判断是否为监听端口（完全对齐Python逻辑）
+func IsListeningPort(c netinfo.Connection) bool {
 	if c.Protocol == "TCP" && c.Status == "LISTEN" {
 		return true
 	}
-	if c.Protocol == "UDP" && c.LocalAddr != ":0" { // UDP 只需本地端口非零
+	if c.Protocol == "UDP" && c.LocalAddr.Port() != 0 { // UDP 只需本地端口非零
 		return true
 	}
 	return false
 }
 
-func (m *ListenerMonitor) Initialize() error {
-	conns, err := netinfo.GetConnections()
-	if err != nil {
-		return err
+// collectListeners 提取快照中的监听端点。
+// 同一端点可能出现多次(多个进程继承同一套接字、SO_REUSEPORT),
+// 此时固定选择PID最小的一个,避免每轮在多个持有者之间来回切换
+func collectListeners(snap *netinfo.Snapshot) map[string]netinfo.Connection {
+	listeners := make(map[string]netinfo.Connection)
+	for _, c := range snap.Connections {
+		if !IsListeningPort(c) {
+			continue
+		}
+		key := listenerKey(c)
+		if old, exists := listeners[key]; exists && !preferOwner(c, old) {
+			continue
+		}
+		listeners[key] = c
 	}
+	return listeners
+}
 
-	for _, c := range conns {
-		if isListeningPort(c) && !m.filter.ShouldFilter(c) {
-			port := extractPort(c.LocalAddr)
-			m.initialState[port] = c
-		}
+// preferOwner 判断 c 是否比 old 更适合作为端点的持有者
+func preferOwner(c, old netinfo.Connection) bool {
+	if old.PID == 0 {
+		return c.PID != 0 || c.Inode < old.Inode
+	}
+	if c.PID == 0 {
+		return false
 	}
-	return nil
+	if c.PID != old.PID {
+		return c.PID < old.PID
+	}
+	return c.Inode < old.Inode
 }
 
-func (m *ListenerMonitor) CheckChanges() ([]netinfo.Connection, []netinfo.Connection, error) {
-	currentConns, err := netinfo.GetConnections()
-	if err != nil {
-		return nil, nil, err
+// SetBaseline 以给定快照作为基线
+func (m *ListenerMonitor) SetBaseline(snap *netinfo.Snapshot) {
+	for key, c := range collectListeners(snap) {
+		m.initialState[key] = trackedListener{Connection: c, reported: !m.filter.ShouldFilter(c)}
 	}
+}
 
+// CheckChanges 对比快照与上一次状态,返回新增、关闭以及持有进程发生变化的监听端口。
+// 同一端点的套接字inode变化但进程未变时,视为先关闭再重新监听。
+// 端点是否报告由过滤器决定,持有者变化后按新的持有者重新判断:
+// 从可见变为被过滤视为关闭,从被过滤变为可见视为新增
+func (m *ListenerMonitor) CheckChanges(snap *netinfo.Snapshot) ([]netinfo.Connection, []netinfo.Connection, []ListenerOwnerChange) {
 	var newListeners []netinfo.Connection
 	var closedListeners []netinfo.Connection
-	currentState := make(map[uint32]netinfo.Connection)
-
-	for _, c := range currentConns {
-		if isListeningPort(c) {
-			port := extractPort(c.LocalAddr)
-			currentState[port] = c
-
-			// 检查是否被过滤器过滤
-			shouldFilter := m.filter.ShouldFilter(c)
+	var ownerChanges []ListenerOwnerChange
+	currentState := make(map[string]trackedListener)
+
+	for key, c := range collectListeners(snap) {
+		old, exists := m.initialState[key]
+		ownerChanged := exists && old.PID != c.PID
+		reopened := exists && old.Inode != 0 && c.Inode != 0 && old.Inode != c.Inode
+		if exists && !ownerChanged && !reopened {
+			currentState[key] = trackedListener{Connection: c, reported: old.reported}
+			continue
+		}
 
+		// 检查是否被过滤器过滤
+		reported := !m.filter.ShouldFilter(c)
+		currentState[key] = trackedListener{Connection: c, reported: reported}
+		if ownerChanged && old.reported && reported {
+			ownerChanges = append(ownerChanges, ListenerOwnerChange{Old: old.Connection, New: c})
+			continue
+		}
+		if exists && old.reported {
+			closedListeners = append(closedListeners, old.Connection)
+		}
+		if reported {
 			// 检查新监听端口
-			if _, exists := m.initialState[port]; !exists && !shouldFilter {
-				newListeners = append(newListeners, c)
-			}
+			newListeners = append(newListeners, c)
 		}
 	}
 
 	// 检查关闭的监听端口
-	for port, oldConn := range m.initialState {
-		if _, exists := currentState[port]; !exists {
-			closedListeners = append(closedListeners, oldConn)
+	for key, old := range m.initialState {
+		if _, exists := currentState[key]; !exists && old.reported {
+			closedListeners = append(closedListeners, old.Connection)
 		}
 	}
 
 	m.initialState = currentState
-	return newListeners, closedListeners, nil
+	return newListeners, closedListeners, ownerChanges
 }
 
-func (m *ListenerMonitor) LogNewListeners(listeners []netinfo.Connection) {
-	for _, l := range listeners {
-		logger.LogConnection(logger.ListenerWriter, "LISTEN", l.Protocol,
-			l.LocalAddr, "", l.PID, l.ProcessName, true)
+// refilter 过滤条件变化后重新判断已跟踪的监听端点是否报告,不产生事件
+func (m *ListenerMonitor) refilter() {
+	for key, l := range m.initialState {
+		l.reported = !m.filter.ShouldFilter(l.Connection)
+		m.initialState[key] = l
 	}
 }
-
-func (m *ListenerMonitor) LogClosedListeners(listeners []netinfo.Connection) {
-	for _, l := range listeners {
-		logger.LogConnection(logger.ListenerWriter, "LISTEN", l.Protocol,
-			l.LocalAddr, "", l.PID, l.ProcessName, false)
-	}
-}
\ No newline at end of file
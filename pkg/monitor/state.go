@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"fmt"
+	"netmonitor/pkg/netinfo"
+	"time"
+)
+
+// 告警类型
+const (
+	AlertCloseWait = "close_wait" // 进程的CLOSE_WAIT套接字堆积,通常是应用未调用close
+)
+
+// StateTransition 被跟踪的TCP套接字发生了状态变化
+type StateTransition struct {
+	Conn  netinfo.Connection // 变化后的连接
+	From  string             // 原状态
+	To    string             // 新状态
+	Since time.Time          // 进入原状态的时间(首次观测到)
+}
+
+// Alert 异常模式告警
+type Alert struct {
+	Kind        string
+	PID         int32
+	ProcessName string
+	Count       int
+	Message     string
+}
+
+// StateMonitor 跟踪所有TCP套接字的状态机,报告状态变化和异常模式
+type StateMonitor struct {
+	sockets            map[string]trackedState
+	filter             *netinfo.ConnectionFilter
+	closeWaitThreshold int
+	closeWaitAlerted   map[int32]bool
+}
+
+// trackedState 套接字上一次观测到的状态
+type trackedState struct {
+	conn  netinfo.Connection
+	since time.Time
+}
+
+// NewStateMonitor 创建状态监控器,closeWaitThreshold 为单个进程CLOSE_WAIT套接字数的告警阈值(0表示不告警)
+func NewStateMonitor(filter *netinfo.ConnectionFilter, closeWaitThreshold int) *StateMonitor {
+	return &StateMonitor{
+		sockets:            make(map[string]trackedState),
+		filter:             filter,
+		closeWaitThreshold: closeWaitThreshold,
+		closeWaitAlerted:   make(map[int32]bool),
+	}
+}
+
+// SetCloseWaitThreshold 修改CLOSE_WAIT告警阈值(0表示不告警)
+func (m *StateMonitor) SetCloseWaitThreshold(n int) {
+	m.closeWaitThreshold = n
+}
+
+func (m *StateMonitor) getKey(c netinfo.Connection) string {
+	return fmt.Sprintf("%s|%s|%s", c.Protocol, c.LocalAddr, c.RemoteAddr)
+}
+
+// SetBaseline 以给定快照作为基线
+func (m *StateMonitor) SetBaseline(snap *netinfo.Snapshot) {
+	for _, c := range snap.Connections {
+		if c.Protocol == "TCP" {
+			m.sockets[m.getKey(c)] = trackedState{conn: c, since: snap.Timestamp}
+		}
+	}
+}
+
+// CheckChanges 对比快照与上一次状态,返回状态变化以及本轮触发的告警
+func (m *StateMonitor) CheckChanges(snap *netinfo.Snapshot) ([]StateTransition, []Alert) {
+	var transitions []StateTransition
+	current := make(map[string]trackedState)
+	closeWait := make(map[int32]int)
+	names := make(map[int32]string)
+
+	for _, c := range snap.Connections {
+		if c.Protocol != "TCP" {
+			continue
+		}
+
+		key := m.getKey(c)
+		state := trackedState{conn: c, since: snap.Timestamp}
+		if old, exists := m.sockets[key]; exists {
+			if old.conn.Status == c.Status {
+				state.since = old.since
+			} else if !m.filter.ShouldFilter(c) {
+				transitions = append(transitions, StateTransition{
+					Conn:  c,
+					From:  old.conn.Status,
+					To:    c.Status,
+					Since: old.since,
+				})
+			}
+		}
+		current[key] = state
+
+		if c.Status == "CLOSE_WAIT" && c.PID > 0 && !m.filter.ShouldFilter(c) {
+			closeWait[c.PID]++
+			names[c.PID] = c.ProcessName
+		}
+	}
+
+	m.sockets = current
+	return transitions, m.checkCloseWait(closeWait, names)
+}
+
+// checkCloseWait 进程的CLOSE_WAIT数超过阈值时告警一次,降到阈值以下后重新计算
+func (m *StateMonitor) checkCloseWait(counts map[int32]int, names map[int32]string) []Alert {
+	if m.closeWaitThreshold <= 0 {
+		return nil
+	}
+
+	var alerts []Alert
+	for pid, count := range counts {
+		if count < m.closeWaitThreshold || m.closeWaitAlerted[pid] {
+			continue
+		}
+		m.closeWaitAlerted[pid] = true
+		alerts = append(alerts, Alert{
+			Kind:        AlertCloseWait,
+			PID:         pid,
+			ProcessName: names[pid],
+			Count:       count,
+			Message: fmt.Sprintf("进程 %s(PID:%d) 有 %d 个CLOSE_WAIT连接(阈值 %d),可能未正确关闭套接字",
+				names[pid], pid, count, m.closeWaitThreshold),
+		})
+	}
+
+	for pid := range m.closeWaitAlerted {
+		if counts[pid] < m.closeWaitThreshold {
+			delete(m.closeWaitAlerted, pid)
+		}
+	}
+	return alerts
+}
@@ -2,11 +2,33 @@ package monitor
 
 import (
 	"fmt"
+	"netmonitor/pkg/event"
 	"netmonitor/pkg/netinfo"
 	"sync"
 	"time"
 )
 
+// LifetimeBuckets 连接存活时长分布的区间上限
+var LifetimeBuckets = []time.Duration{
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	10 * time.Minute,
+	time.Hour,
+}
+
+// lifetimeBucketLabels 与 LifetimeBuckets 对应的区间名称,最后一项为超过最大上限的连接
+var lifetimeBucketLabels = []string{"<1s", "1s-10s", "10s-1m", "1m-10m", "10m-1h", ">1h"}
+
+// ShortLivedThreshold 存活时长低于该值的连接视为短连接
+const ShortLivedThreshold = 10 * time.Second
+
+// LifetimeBucket 存活时长分布中的一个区间
+type LifetimeBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
 type Stats struct {
 	TotalEstablished  int
 	TotalListeners    int
@@ -14,33 +36,66 @@ type Stats struct {
 	ClosedConnections int
 	NewListeners      int
 	ClosedListeners   int
+	OwnerChanges      int
+	StateChanges      int
+	Alerts            int
 	ByProtocol        map[string]int
+	ByFamily          map[string]int
+	ByState           map[string]int // TCP连接按状态分布
 	ByPID             map[int32]int
 	LastUpdate        time.Time
 	RecentNew         []time.Time
 	RecentClosed      []time.Time
+	Lifetimes         []int         // 已关闭连接的存活时长分布,下标对应 LifetimeBuckets
+	ShortLived        int           // 存活时长低于 ShortLivedThreshold 的已关闭连接数
+	LongLived         int           // 其余已关闭连接数
+	Collections       int           // 采集次数
+	CollectErrors     int           // 采集失败次数
+	CollectDuration   time.Duration // 最近一次采集耗时
+	CollectTotal      time.Duration // 累计采集耗时
 	mu                sync.RWMutex
 }
 
+// Counters 自启动以来的累计计数
+type Counters struct {
+	NewConnections    int
+	ClosedConnections int
+	NewListeners      int
+	ClosedListeners   int
+	OwnerChanges      int
+	StateChanges      int
+	Alerts            int
+	Collections       int
+	CollectErrors     int
+	CollectDuration   time.Duration
+	CollectTotal      time.Duration
+}
+
 func NewStats() *Stats {
 	return &Stats{
-		ByProtocol:  make(map[string]int),
-		ByPID:      make(map[int32]int),
-		RecentNew:   make([]time.Time, 0),
+		ByProtocol:   make(map[string]int),
+		ByFamily:     make(map[string]int),
+		ByState:      make(map[string]int),
+		ByPID:        make(map[int32]int),
+		RecentNew:    make([]time.Time, 0),
 		RecentClosed: make([]time.Time, 0),
+		Lifetimes:    make([]int, len(LifetimeBuckets)+1),
 	}
 }
 
-func (s *Stats) Update(currentConns []netinfo.Connection) {
+// Update 根据快照刷新当前连接统计
+func (s *Stats) Update(snap *netinfo.Snapshot) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.TotalEstablished = 0
 	s.TotalListeners = 0
 	s.ByProtocol = make(map[string]int)
+	s.ByFamily = make(map[string]int)
+	s.ByState = make(map[string]int)
 	s.ByPID = make(map[int32]int)
 
-	for _, conn := range currentConns {
+	for _, conn := range snap.Connections {
 		if conn.Status == "ESTABLISHED" {
 			s.TotalEstablished++
 		} else if conn.Status == "LISTEN" {
@@ -48,17 +103,41 @@ func (s *Stats) Update(currentConns []netinfo.Connection) {
 		}
 
 		s.ByProtocol[conn.Protocol]++
+		s.ByFamily[conn.Family]++
+		if conn.Protocol == "TCP" {
+			s.ByState[conn.Status]++
+		}
 		if conn.PID > 0 {
 			s.ByPID[conn.PID]++
 		}
 	}
 
-	s.LastUpdate = time.Now()
+	s.LastUpdate = snap.Timestamp
 
 	// 清理60秒之前的记录
 	s.cleanupOldEvents()
 }
 
+// HandleEvent 根据总线上的事件更新计数,作为事件总线的订阅者使用
+func (s *Stats) HandleEvent(e event.Event) {
+	switch e.Kind {
+	case event.ListenerOpened:
+		s.RecordNewListener(e.Conn.Protocol, e.Conn.PID)
+	case event.ListenerClosed:
+		s.RecordClosedListener(e.Conn.Protocol, e.Conn.PID)
+	case event.ListenerOwnerChanged:
+		s.RecordListenerOwnerChange(e.Conn.Protocol, int32(e.Int(event.MetaOldPID)), e.Conn.PID)
+	case event.ConnOpened:
+		s.RecordNewConnection(e.Conn.Protocol, e.Conn.PID)
+	case event.ConnClosed:
+		s.RecordClosedConnection(e.Conn.Protocol, e.Conn.PID, e.Duration(event.MetaLifetime), e.Bool(event.MetaBaseline))
+	case event.StateChanged:
+		s.RecordStateChange(e.Meta(event.MetaFromState), e.Meta(event.MetaToState))
+	case event.Alert:
+		s.RecordAlert(e.Meta(event.MetaAlertKind))
+	}
+}
+
 func (s *Stats) RecordNewConnection(protocol string, pid int32) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -74,12 +153,23 @@ func (s *Stats) RecordNewConnection(protocol string, pid int32) {
 	s.cleanupOldEvents()
 }
 
-func (s *Stats) RecordClosedConnection(protocol string, pid int32) {
+// RecordClosedConnection 记录关闭的连接及其存活时长。
+// 启动前已存在的连接(baseline)只知道存活时长的下限,不计入存活时长分布
+func (s *Stats) RecordClosedConnection(protocol string, pid int32, lifetime time.Duration, baseline bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.ClosedConnections++
 
+	if !baseline {
+		s.Lifetimes[lifetimeBucket(lifetime)]++
+		if lifetime < ShortLivedThreshold {
+			s.ShortLived++
+		} else {
+			s.LongLived++
+		}
+	}
+
 	// 记录时间戳
 	s.RecentClosed = append(s.RecentClosed, time.Now())
 	s.cleanupOldEvents()
@@ -99,6 +189,94 @@ func (s *Stats) RecordClosedListener(protocol string, pid int32) {
 	s.ClosedListeners++
 }
 
+func (s *Stats) RecordListenerOwnerChange(protocol string, oldPID, newPID int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.OwnerChanges++
+}
+
+func (s *Stats) RecordStateChange(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.StateChanges++
+}
+
+func (s *Stats) RecordAlert(kind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Alerts++
+}
+
+// RecordCollection 记录一次采集的耗时和结果
+func (s *Stats) RecordCollection(duration time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Collections++
+	s.CollectDuration = duration
+	s.CollectTotal += duration
+	if err != nil {
+		s.CollectErrors++
+	}
+}
+
+// GetCounters 返回累计计数
+func (s *Stats) GetCounters() Counters {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Counters{
+		NewConnections:    s.NewConnections,
+		ClosedConnections: s.ClosedConnections,
+		NewListeners:      s.NewListeners,
+		ClosedListeners:   s.ClosedListeners,
+		OwnerChanges:      s.OwnerChanges,
+		StateChanges:      s.StateChanges,
+		Alerts:            s.Alerts,
+		Collections:       s.Collections,
+		CollectErrors:     s.CollectErrors,
+		CollectDuration:   s.CollectDuration,
+		CollectTotal:      s.CollectTotal,
+	}
+}
+
+// GetStateCounts 返回TCP连接按状态的分布
+func (s *Stats) GetStateCounts() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]int, len(s.ByState))
+	for state, count := range s.ByState {
+		result[state] = count
+	}
+	return result
+}
+
+// lifetimeBucket 返回存活时长所属的区间下标
+func lifetimeBucket(lifetime time.Duration) int {
+	for i, bound := range LifetimeBuckets {
+		if lifetime < bound {
+			return i
+		}
+	}
+	return len(LifetimeBuckets)
+}
+
+// GetLifetimeHistogram 返回已关闭连接的存活时长分布
+func (s *Stats) GetLifetimeHistogram() []LifetimeBucket {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]LifetimeBucket, len(s.Lifetimes))
+	for i, count := range s.Lifetimes {
+		result[i] = LifetimeBucket{Label: lifetimeBucketLabels[i], Count: count}
+	}
+	return result
+}
+
 func (s *Stats) GetRecentNewCount() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -153,6 +331,31 @@ func (s *Stats) GetDisplay() string {
 		}
 	}
 
+	if len(s.ByState) > 0 {
+		result += "\nTCP状态分布:\n"
+		for state, count := range s.ByState {
+			result += fmt.Sprintf("  %s: %d\n", state, count)
+		}
+	}
+
+	if s.Alerts > 0 {
+		result += fmt.Sprintf("\n告警: %d  状态变化: %d\n", s.Alerts, s.StateChanges)
+	}
+
+	if len(s.ByFamily) > 0 {
+		result += "\n按地址族分布:\n"
+		for family, count := range s.ByFamily {
+			result += fmt.Sprintf("  %s: %d\n", family, count)
+		}
+	}
+
+	if s.ShortLived+s.LongLived > 0 {
+		result += fmt.Sprintf("\n连接存活时长 (短连接<%s: %d  长连接: %d):\n", ShortLivedThreshold, s.ShortLived, s.LongLived)
+		for i, count := range s.Lifetimes {
+			result += fmt.Sprintf("  %s: %d\n", lifetimeBucketLabels[i], count)
+		}
+	}
+
 	// 显示前5个最活跃的进程
 	if len(s.ByPID) > 0 {
 		type PIDCount struct {
@@ -189,7 +392,12 @@ func (s *Stats) ResetCurrentSession() {
 	s.ClosedConnections = 0
 	s.NewListeners = 0
 	s.ClosedListeners = 0
+	s.OwnerChanges = 0
+	s.StateChanges = 0
+	s.Alerts = 0
+	s.Lifetimes = make([]int, len(LifetimeBuckets)+1)
+	s.ShortLived = 0
+	s.LongLived = 0
 	s.RecentNew = make([]time.Time, 0)
 	s.RecentClosed = make([]time.Time, 0)
 }
-
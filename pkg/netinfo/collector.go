@@ -0,0 +1,167 @@
+package netinfo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 连接采集后端
+const (
+	BackendGopsutil = "gopsutil" // 通过gopsutil采集(跨平台)
+	BackendProcfs   = "procfs"   // 直接解析 /proc/net (仅Linux)
+	BackendNetlink  = "netlink"  // NETLINK_SOCK_DIAG,内核侧按状态过滤 (仅Linux)
+)
+
+// ErrNetlinkUnavailable 当前环境无法使用netlink采集
+var ErrNetlinkUnavailable = errors.New("netlink sock_diag不可用")
+
+// ErrReplayExhausted 回放的快照已全部返回
+var ErrReplayExhausted = errors.New("回放快照已耗尽")
+
+// Collector 连接采集器,每次调用 Collect 生成一份快照
+type Collector interface {
+	Collect() (*Snapshot, error)
+}
+
+// CollectorFunc 将普通的采集函数适配为 Collector
+type CollectorFunc func() ([]Connection, error)
+
+func (f CollectorFunc) Collect() (*Snapshot, error) {
+	conns, err := f()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		Timestamp:   time.Now(),
+		Connections: conns,
+	}, nil
+}
+
+// NewCollector 根据后端名称创建采集器。
+// netlinkStates 为netlink后端在内核侧保留的TCP状态(留空表示全部),其他后端忽略该参数。
+// netlink不可用时(非Linux、内核未启用inet_diag、容器禁止等)返回回退到gopsutil的采集器,
+// 同时返回包装了 ErrNetlinkUnavailable 的错误,调用方可以只记录警告
+func NewCollector(backend string, netlinkStates []string) (Collector, error) {
+	switch strings.ToLower(backend) {
+	case "", BackendGopsutil:
+		return CollectorFunc(GetConnections), nil
+	case BackendProcfs:
+		if !procfsSupported {
+			return nil, fmt.Errorf("采集后端 %s 仅支持Linux", BackendProcfs)
+		}
+		return CollectorFunc(NewProcfsCollector("/proc").Connections), nil
+	case BackendNetlink:
+		nl := NewNetlinkCollector(netlinkStates...)
+		collector := CollectorFunc(func() ([]Connection, error) {
+			conns, err := nl.Connections()
+			if errors.Is(err, ErrNetlinkUnavailable) {
+				return GetConnections()
+			}
+			return conns, err
+		})
+		if err := nl.Probe(); err != nil {
+			return collector, fmt.Errorf("%w, 已回退到 %s", err, BackendGopsutil)
+		}
+		return collector, nil
+	default:
+		return nil, fmt.Errorf("未知的采集后端: %s", backend)
+	}
+}
+
+// ReplayCollector 按顺序返回预先给定的快照,用于测试和事故复现
+type ReplayCollector struct {
+	snapshots []*Snapshot
+	next      int
+	mu        sync.Mutex
+}
+
+func NewReplayCollector(snapshots ...*Snapshot) *ReplayCollector {
+	return &ReplayCollector{snapshots: snapshots}
+}
+
+// LoadReplay 从录制文件(每行一个JSON快照)加载回放采集器
+func LoadReplay(path string) (*ReplayCollector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开回放文件失败: %w", err)
+	}
+	defer f.Close()
+
+	var snapshots []*Snapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snap); err != nil {
+			return nil, fmt.Errorf("解析回放文件第%d行失败: %w", line, err)
+		}
+		snapshots = append(snapshots, &snap)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取回放文件失败: %w", err)
+	}
+	return NewReplayCollector(snapshots...), nil
+}
+
+// Collect 返回下一份快照,全部返回后返回 ErrReplayExhausted
+func (r *ReplayCollector) Collect() (*Snapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.snapshots) {
+		return nil, ErrReplayExhausted
+	}
+	snap := r.snapshots[r.next]
+	r.next++
+	return snap, nil
+}
+
+// Remaining 返回尚未回放的快照数量
+func (r *ReplayCollector) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.snapshots) - r.next
+}
+
+// RecordingCollector 包装另一个采集器,把每次采集到的快照写入录制文件,供 LoadReplay 回放
+type RecordingCollector struct {
+	collector Collector
+	w         io.Writer
+	mu        sync.Mutex
+}
+
+func NewRecordingCollector(collector Collector, w io.Writer) *RecordingCollector {
+	return &RecordingCollector{collector: collector, w: w}
+}
+
+func (r *RecordingCollector) Collect() (*Snapshot, error) {
+	snap, err := r.collector.Collect()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, fmt.Errorf("序列化快照失败: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("写入录制文件失败: %w", err)
+	}
+	return snap, nil
+}
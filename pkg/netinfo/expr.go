@@ -0,0 +1,559 @@
+package netinfo
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FilterExpr 编译后的过滤表达式,例如
+//
+//	proto == tcp and (dport in 443,8443 or dst in 10.0.0.0/8) and not process ~ "^chrome"
+//
+// 表达式只解析一次,Match 可以被多个goroutine同时调用
+type FilterExpr struct {
+	src   string
+	match func(Connection) bool
+}
+
+// Match 连接是否满足表达式
+func (e *FilterExpr) Match(c Connection) bool {
+	return e.match(c)
+}
+
+// String 表达式原文
+func (e *FilterExpr) String() string {
+	return e.src
+}
+
+// ExprError 过滤表达式的语法或取值错误
+type ExprError struct {
+	Expr string // 表达式原文
+	Pos  int    // 出错位置(字节偏移)
+	Msg  string
+}
+
+// Column 出错位置的列号(从1开始,按字符计)
+func (e *ExprError) Column() int {
+	return utf8.RuneCountInString(e.Expr[:e.Pos]) + 1
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("过滤表达式第%d列: %s", e.Column(), e.Msg)
+}
+
+// Caret 表达式原文及指向出错位置的标记,用于多行输出
+func (e *ExprError) Caret() string {
+	return e.Expr + "\n" + strings.Repeat(" ", e.Column()-1) + "^"
+}
+
+// 表达式中可用的字段
+const exprFields = "proto, family, state, process, exe, cmdline, pid, uid, src, sport, dst, dport, addr, port, rxq, txq"
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindNumber
+	kindIP
+)
+
+// exprField 字段的类型和取值方式,addr/port 同时匹配本地和远程两端
+type exprField struct {
+	kind    fieldKind
+	str     func(Connection) string
+	num     func(Connection) uint64
+	addrs   func(Connection) []netip.Addr
+	numbers func(Connection) []uint64
+}
+
+var exprFieldTable = map[string]exprField{
+	"proto":   {kind: kindString, str: func(c Connection) string { return c.Protocol }},
+	"family":  {kind: kindString, str: func(c Connection) string { return c.Family }},
+	"state":   {kind: kindString, str: func(c Connection) string { return c.Status }},
+	"process": {kind: kindString, str: func(c Connection) string { return c.ProcessName }},
+	"exe":     {kind: kindString, str: func(c Connection) string { return c.Exe }},
+	"cmdline": {kind: kindString, str: func(c Connection) string { return c.Cmdline }},
+	"pid":     {kind: kindNumber, num: func(c Connection) uint64 { return uint64(c.PID) }},
+	"uid":     {kind: kindNumber, num: func(c Connection) uint64 { return uint64(c.UID) }},
+	"rxq":     {kind: kindNumber, num: func(c Connection) uint64 { return uint64(c.RxQueue) }},
+	"txq":     {kind: kindNumber, num: func(c Connection) uint64 { return uint64(c.TxQueue) }},
+	"sport":   {kind: kindNumber, num: func(c Connection) uint64 { return uint64(c.LocalAddr.Port()) }},
+	"dport":   {kind: kindNumber, num: func(c Connection) uint64 { return uint64(c.RemoteAddr.Port()) }},
+	"port": {kind: kindNumber, numbers: func(c Connection) []uint64 {
+		return []uint64{uint64(c.LocalAddr.Port()), uint64(c.RemoteAddr.Port())}
+	}},
+	"src": {kind: kindIP, addrs: func(c Connection) []netip.Addr { return []netip.Addr{c.LocalAddr.Addr()} }},
+	"dst": {kind: kindIP, addrs: func(c Connection) []netip.Addr { return []netip.Addr{c.RemoteAddr.Addr()} }},
+	"addr": {kind: kindIP, addrs: func(c Connection) []netip.Addr {
+		return []netip.Addr{c.LocalAddr.Addr(), c.RemoteAddr.Addr()}
+	}},
+}
+
+// 字段别名
+var exprFieldAliases = map[string]string{
+	"protocol": "proto",
+	"status":   "state",
+	"name":     "process",
+	"laddr":    "src",
+	"lport":    "sport",
+	"raddr":    "dst",
+	"rport":    "dport",
+}
+
+// ParseFilterExpr 解析并编译过滤表达式。
+//
+// 条件为 字段 运算符 值,用 and/or/not(或 && || !)和括号组合:
+//   - 字符串字段 proto, family, state, process, exe, cmdline: == != (不区分大小写)、~ !~ (正则表达式)、in
+//   - 数值字段 pid, uid, sport, dport, port, rxq, txq: == != < <= > >=、in(可以是范围,如 8000-8100)
+//   - 地址字段 src, dst, addr: == != in,值可以是IP或CIDR
+//
+// in 的值以逗号分隔,也可以放在括号中;含空格或特殊字符的值用引号括起来
+func ParseFilterExpr(s string) (*FilterExpr, error) {
+	p := &exprParser{src: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokEOF {
+		return nil, p.errorf(p.peek(), "表达式为空")
+	}
+	match, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "多余的 %s", t)
+	}
+	return &FilterExpr{src: s, match: match}, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "表达式结尾"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// isKeyword 不区分大小写地判断单词是否为关键字
+func (t token) isKeyword(words ...string) bool {
+	if t.kind != tokWord {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+type exprParser struct {
+	src    string
+	tokens []token
+	next   int
+}
+
+func (p *exprParser) errorf(t token, format string, args ...interface{}) error {
+	return &ExprError{Expr: p.src, Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// 运算符,较长的在前
+var exprOps = []string{"==", "!=", "!~", "<=", ">=", "&&", "||", "~", "<", ">", "!", "="}
+
+// lex 将表达式拆分为单词、字符串、运算符、括号和逗号
+func (p *exprParser) lex() error {
+	s := p.src
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			p.tokens = append(p.tokens, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			p.tokens = append(p.tokens, token{tokRParen, ")", i})
+			i++
+		case r == ',':
+			p.tokens = append(p.tokens, token{tokComma, ",", i})
+			i++
+		case r == '"' || r == '\'':
+			text, n, ok := readQuoted(s[i:])
+			if !ok {
+				return &ExprError{Expr: s, Pos: i, Msg: "引号没有闭合"}
+			}
+			p.tokens = append(p.tokens, token{tokString, text, i})
+			i += n
+		default:
+			if op := matchOp(s[i:]); op != "" {
+				text := op
+				if op == "=" {
+					text = "==" // 允许 proto = tcp
+				}
+				p.tokens = append(p.tokens, token{tokOp, text, i})
+				i += len(op)
+				continue
+			}
+			start := i
+			for i < len(s) {
+				r, size := utf8.DecodeRuneInString(s[i:])
+				if unicode.IsSpace(r) || strings.ContainsRune(`(),"'`, r) || matchOp(s[i:]) != "" {
+					break
+				}
+				i += size
+			}
+			p.tokens = append(p.tokens, token{tokWord, s[start:i], start})
+		}
+	}
+	p.tokens = append(p.tokens, token{tokEOF, "", len(s)})
+	return nil
+}
+
+func matchOp(s string) string {
+	for _, op := range exprOps {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// readQuoted 读取引号括起的字符串。双引号中只有 \" 和 \\ 是转义,
+// 其他反斜杠原样保留,正则表达式中的 \d 等无需重复转义;单引号中不转义
+func readQuoted(s string) (string, int, bool) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, true
+		case c == '\\' && quote == '"' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\'):
+			b.WriteByte(s[i+1])
+			i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *exprParser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *exprParser) parseOr() (func(Connection) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.isKeyword("or") || t.kind == tokOp && t.text == "||"; t = p.peek() {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(c Connection) bool { return l(c) || right(c) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (func(Connection) bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.isKeyword("and") || t.kind == tokOp && t.text == "&&"; t = p.peek() {
+		p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(c Connection) bool { return l(c) && right(c) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (func(Connection) bool, error) {
+	t := p.peek()
+	switch {
+	case t.isKeyword("not") || t.kind == tokOp && t.text == "!":
+		p.advance()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(c Connection) bool { return !inner(c) }, nil
+	case t.kind == tokLParen:
+		p.advance()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.advance(); t.kind != tokRParen {
+			return nil, p.errorf(t, "缺少 \")\",得到 %s", t)
+		}
+		return inner, nil
+	}
+	return p.parseCondition()
+}
+
+// parseCondition 解析 字段 运算符 值 或 字段 [not] in 值列表
+func (p *exprParser) parseCondition() (func(Connection) bool, error) {
+	ft := p.advance()
+	if ft.kind != tokWord || ft.isKeyword("and", "or", "in") {
+		return nil, p.errorf(ft, "期望字段名,得到 %s", ft)
+	}
+	name := strings.ToLower(ft.text)
+	if alias, ok := exprFieldAliases[name]; ok {
+		name = alias
+	}
+	field, ok := exprFieldTable[name]
+	if !ok {
+		return nil, p.errorf(ft, "未知的字段 %q,可用字段: %s", ft.text, exprFields)
+	}
+
+	ot := p.advance()
+	switch {
+	case ot.isKeyword("in"):
+		return p.compileIn(name, field, false)
+	case ot.isKeyword("not") && p.peek().isKeyword("in"):
+		p.advance()
+		return p.compileIn(name, field, true)
+	case ot.kind != tokOp || ot.text == "!" || ot.text == "&&" || ot.text == "||":
+		return nil, p.errorf(ot, "字段 %s 之后期望运算符(== != ~ !~ < <= > >= in),得到 %s", name, ot)
+	}
+
+	vt := p.advance()
+	if vt.kind != tokWord && vt.kind != tokString {
+		return nil, p.errorf(vt, "运算符 %s 之后期望值,得到 %s", ot.text, vt)
+	}
+	return p.compileCompare(name, field, ot, vt)
+}
+
+// parseList 解析 in 之后的值列表: a,b,c 或 (a, b, c)
+func (p *exprParser) parseList() ([]token, error) {
+	paren := p.peek().kind == tokLParen
+	if paren {
+		p.advance()
+	}
+	var values []token
+	for {
+		t := p.advance()
+		if t.kind != tokWord && t.kind != tokString {
+			return nil, p.errorf(t, "in 的值列表中期望值,得到 %s", t)
+		}
+		values = append(values, t)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.advance()
+	}
+	if paren {
+		if t := p.advance(); t.kind != tokRParen {
+			return nil, p.errorf(t, "值列表缺少 \")\",得到 %s", t)
+		}
+	}
+	return values, nil
+}
+
+func (p *exprParser) compileCompare(name string, field exprField, op, value token) (func(Connection) bool, error) {
+	switch field.kind {
+	case kindString:
+		switch op.text {
+		case "==", "!=":
+			want := value.text
+			eq := func(c Connection) bool { return strings.EqualFold(field.str(c), want) }
+			return negateIf(eq, op.text == "!="), nil
+		case "~", "!~":
+			re, err := regexp.Compile(value.text)
+			if err != nil {
+				return nil, p.errorf(value, "无效的正则表达式: %v", err)
+			}
+			m := func(c Connection) bool { return re.MatchString(field.str(c)) }
+			return negateIf(m, op.text == "!~"), nil
+		}
+	case kindNumber:
+		n, err := p.parseNumber(name, value)
+		if err != nil {
+			return nil, err
+		}
+		var cmp func(uint64) bool
+		switch op.text {
+		case "==", "!=":
+			// port 同时比较两端时,!= 表示两端都不等于
+			eq := numberMatcher(field, func(v uint64) bool { return v == n })
+			return negateIf(eq, op.text == "!="), nil
+		case "<":
+			cmp = func(v uint64) bool { return v < n }
+		case "<=":
+			cmp = func(v uint64) bool { return v <= n }
+		case ">":
+			cmp = func(v uint64) bool { return v > n }
+		case ">=":
+			cmp = func(v uint64) bool { return v >= n }
+		}
+		if cmp != nil {
+			return numberMatcher(field, cmp), nil
+		}
+	case kindIP:
+		switch op.text {
+		case "==", "!=":
+			prefix, err := p.parsePrefix(value)
+			if err != nil {
+				return nil, err
+			}
+			return negateIf(ipMatcher(field, []netip.Prefix{prefix}), op.text == "!="), nil
+		}
+	}
+	return nil, p.errorf(op, "字段 %s 不支持运算符 %s", name, op.text)
+}
+
+func (p *exprParser) compileIn(name string, field exprField, negate bool) (func(Connection) bool, error) {
+	values, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	var match func(Connection) bool
+	switch field.kind {
+	case kindString:
+		match = func(c Connection) bool {
+			s := field.str(c)
+			for _, v := range values {
+				if strings.EqualFold(s, v.text) {
+					return true
+				}
+			}
+			return false
+		}
+	case kindNumber:
+		type span struct{ lo, hi uint64 }
+		var spans []span
+		for _, v := range values {
+			lo, hi := v, v
+			if a, b, ok := strings.Cut(v.text, "-"); ok && v.kind == tokWord {
+				lo = token{v.kind, a, v.pos}
+				hi = token{v.kind, b, v.pos + len(a) + 1}
+			}
+			l, err := p.parseNumber(name, lo)
+			if err != nil {
+				return nil, err
+			}
+			h, err := p.parseNumber(name, hi)
+			if err != nil {
+				return nil, err
+			}
+			if h < l {
+				return nil, p.errorf(v, "范围 %s 的上限小于下限", v.text)
+			}
+			spans = append(spans, span{l, h})
+		}
+		match = numberMatcher(field, func(n uint64) bool {
+			for _, s := range spans {
+				if n >= s.lo && n <= s.hi {
+					return true
+				}
+			}
+			return false
+		})
+	case kindIP:
+		prefixes := make([]netip.Prefix, 0, len(values))
+		for _, v := range values {
+			prefix, err := p.parsePrefix(v)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		match = ipMatcher(field, prefixes)
+	}
+	return negateIf(match, negate), nil
+}
+
+func (p *exprParser) parseNumber(name string, t token) (uint64, error) {
+	n, err := strconv.ParseUint(t.text, 10, 64)
+	if err != nil {
+		return 0, p.errorf(t, "字段 %s 需要非负整数,得到 %q", name, t.text)
+	}
+	return n, nil
+}
+
+// parsePrefix 解析IP或CIDR,错误位置指向该值
+func (p *exprParser) parsePrefix(t token) (netip.Prefix, error) {
+	prefix, err := ParsePrefix(t.text)
+	if err != nil {
+		return netip.Prefix{}, p.errorf(t, "%v", err)
+	}
+	return prefix, nil
+}
+
+func negateIf(m func(Connection) bool, negate bool) func(Connection) bool {
+	if !negate {
+		return m
+	}
+	return func(c Connection) bool { return !m(c) }
+}
+
+// numberMatcher 数值字段满足 cmp;同时比较两端的字段任一端满足即可
+func numberMatcher(field exprField, cmp func(uint64) bool) func(Connection) bool {
+	if field.numbers != nil {
+		return func(c Connection) bool {
+			for _, n := range field.numbers(c) {
+				if cmp(n) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return func(c Connection) bool { return cmp(field.num(c)) }
+}
+
+// ipMatcher 地址在任一网段内,IPv4映射的IPv6地址按IPv4比较
+func ipMatcher(field exprField, prefixes []netip.Prefix) func(Connection) bool {
+	return func(c Connection) bool {
+		for _, addr := range field.addrs(c) {
+			if prefixesContain(prefixes, addr) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,147 @@
+package netinfo
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// exprTestConns 表达式测试使用的连接,按名称引用
+var exprTestConns = []struct {
+	name string
+	conn Connection
+}{
+	{"web", Connection{
+		Protocol: "TCP", Family: FamilyIPv4, Status: "ESTABLISHED", PID: 100, ProcessName: "nginx",
+		LocalAddr:  netip.MustParseAddrPort("10.0.0.5:443"),
+		RemoteAddr: netip.MustParseAddrPort("203.0.113.7:51000"),
+	}},
+	{"dns", Connection{
+		Protocol: "UDP", Family: FamilyIPv4, Status: "NONE", PID: 200, ProcessName: "systemd-resolved",
+		LocalAddr:  netip.MustParseAddrPort("127.0.0.53:53"),
+		RemoteAddr: netip.MustParseAddrPort("0.0.0.0:0"),
+	}},
+	{"ssh6", Connection{
+		Protocol: "TCP", Family: FamilyIPv6, Status: "LISTEN", PID: 300, ProcessName: "sshd",
+		LocalAddr:  netip.MustParseAddrPort("[::]:22"),
+		RemoteAddr: netip.MustParseAddrPort("[::]:0"),
+	}},
+	{"mapped", Connection{
+		Protocol: "TCP", Family: FamilyIPv6, Status: "ESTABLISHED", PID: 400, ProcessName: "chrome",
+		LocalAddr:  netip.MustParseAddrPort("[::ffff:10.0.0.5]:40000"),
+		RemoteAddr: netip.MustParseAddrPort("[::ffff:10.1.2.3]:8443"),
+	}},
+}
+
+func TestParseFilterExprMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // 匹配的连接名称,按 exprTestConns 的顺序以逗号分隔
+	}{
+		// 优先级: not > and > or
+		{`proto == udp or proto == tcp and sport == 443`, "web,dns"},
+		{`(proto == udp or proto == tcp) and sport == 443`, "web"},
+		{`proto == tcp && sport == 443 || process == sshd`, "web,ssh6"},
+		{`not proto == tcp and port == 53`, "dns"},
+		{`!(proto == tcp and state == LISTEN)`, "web,dns,mapped"},
+		{`proto == TCP and family == ipv6`, "ssh6,mapped"},
+
+		// in 与CIDR列表,IPv4映射的IPv6地址按IPv4匹配
+		{`dst in 10.0.0.0/8, 203.0.113.0/24`, "web,mapped"},
+		{`dst in (192.0.2.0/24)`, ""},
+		{`src in 10.0.0.5`, "web,mapped"},
+		{`addr in ::/0`, "ssh6"},
+		{`dst not in 10.0.0.0/8`, "web,dns,ssh6"},
+		{`src == 127.0.0.0/8`, "dns"},
+
+		// 端口范围
+		{`dport in 8000-9000`, "mapped"},
+		{`port in (22, 50000-60000)`, "web,ssh6"},
+		{`sport not in 1-1023`, "mapped"},
+		{`sport >= 443 and sport < 40000`, "web"},
+
+		// 取反
+		{`not process ~ "^(nginx|sshd)$"`, "dns,mapped"},
+		{`process !~ nginx and state != LISTEN`, "dns,mapped"},
+		{`not not proto == udp`, "dns"},
+		{`process not in (NGINX, sshd)`, "dns,mapped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := ParseFilterExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilterExpr: %v", err)
+			}
+			var got []string
+			for _, c := range exprTestConns {
+				if e.Match(c.conn) {
+					got = append(got, c.name)
+				}
+			}
+			if s := strings.Join(got, ","); s != tt.want {
+				t.Errorf("匹配 %q,期望 %q", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterExprError(t *testing.T) {
+	tests := []struct {
+		expr   string
+		column int
+		msg    string // 错误信息中应包含的内容
+	}{
+		{`proto == tcp and prot == 1`, 18, `未知的字段 "prot"`},
+		{`process == "浏览器" and prot == 1`, 22, `未知的字段 "prot"`},
+		{`(proto == tcp`, 14, `缺少 ")"`},
+		{`proto tcp`, 7, "期望运算符"},
+		{`proto ==`, 9, "期望值"},
+		{`dst < 10.0.0.1`, 5, "不支持运算符 <"},
+		{`dst in 10.0.0.0/33`, 8, "无效的网段"},
+		{`dst in 10.0.0.1, 300.0.0.1`, 18, "无效的IP地址"},
+		{`dport in 9000-8000`, 10, "上限小于下限"},
+		{`port in 80-x`, 12, "需要非负整数"},
+		{`process ~ "("`, 11, "无效的正则表达式"},
+		{`proto == tcp )`, 14, "多余的"},
+		{``, 1, "表达式为空"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseFilterExpr(tt.expr)
+			var exprErr *ExprError
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("期望 *ExprError,得到 %v", err)
+			}
+			if exprErr.Column() != tt.column {
+				t.Errorf("列号 %d,期望 %d (%v)", exprErr.Column(), tt.column, err)
+			}
+			if !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("错误信息 %q 不包含 %q", err.Error(), tt.msg)
+			}
+		})
+	}
+}
+
+func TestExprErrorCaret(t *testing.T) {
+	tests := []struct {
+		expr  string
+		caret string
+	}{
+		{`proto == tcp and prot == 1`, "proto == tcp and prot == 1\n                 ^"},
+		{`process == "浏览器" and prot == 1`, "process == \"浏览器\" and prot == 1\n                     ^"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseFilterExpr(tt.expr)
+		var exprErr *ExprError
+		if !errors.As(err, &exprErr) {
+			t.Fatalf("%s: 期望 *ExprError,得到 %v", tt.expr, err)
+		}
+		if got := exprErr.Caret(); got != tt.caret {
+			t.Errorf("%s: Caret()\n%s\n期望\n%s", tt.expr, got, tt.caret)
+		}
+	}
+}
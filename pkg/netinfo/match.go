@@ -0,0 +1,207 @@
+package netinfo
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParsePrefix 解析IP或CIDR网段,单个IP视为只包含该地址的网段。
+// IPv4映射的IPv6地址和网段(::ffff:10.0.0.0/104)按IPv4处理
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("无效的网段 %q", s)
+		}
+		if addr := prefix.Addr(); addr.Is4In6() {
+			if prefix.Bits() < 96 {
+				return netip.Prefix{}, fmt.Errorf("无效的网段 %q: IPv4映射地址的前缀长度至少为96", s)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("无效的IP地址 %q", s)
+	}
+	addr = addr.WithZone("").Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ParsePrefixes 解析IP或CIDR网段列表
+func ParsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		prefix, err := ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// prefixesContain 地址是否在任一网段内,IPv4映射的IPv6地址按IPv4比较,忽略IPv6地址的zone
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.WithZone("").Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// PortRange 端口范围,单个端口时 Low 与 High 相同。
+// 配置中可写为整数(22)或字符串("22"、"8000-8100")
+type PortRange struct {
+	Low  uint16
+	High uint16
+}
+
+// ParsePortRange 解析端口或以 - 连接的端口范围
+func ParsePortRange(s string) (PortRange, error) {
+	s = strings.TrimSpace(s)
+	low, high, isRange := strings.Cut(s, "-")
+	lo, err := parsePort(low)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("无效的端口 %q", s)
+	}
+	hi := lo
+	if isRange {
+		if hi, err = parsePort(high); err != nil {
+			return PortRange{}, fmt.Errorf("无效的端口范围 %q", s)
+		}
+		if hi < lo {
+			return PortRange{}, fmt.Errorf("端口范围 %q 的上限小于下限", s)
+		}
+	}
+	return PortRange{Low: lo, High: hi}, nil
+}
+
+func parsePort(s string) (uint16, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+	return uint16(n), err
+}
+
+// Contains 端口是否在范围内
+func (r PortRange) Contains(port uint16) bool {
+	return port >= r.Low && port <= r.High
+}
+
+func (r PortRange) String() string {
+	if r.Low == r.High {
+		return strconv.Itoa(int(r.Low))
+	}
+	return fmt.Sprintf("%d-%d", r.Low, r.High)
+}
+
+func (r *PortRange) UnmarshalText(text []byte) error {
+	pr, err := ParsePortRange(string(text))
+	if err != nil {
+		return err
+	}
+	*r = pr
+	return nil
+}
+
+func (r PortRange) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// portsContain 端口是否在任一范围内
+func portsContain(ranges []PortRange, port uint16) bool {
+	for _, r := range ranges {
+		if r.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessMatcher 进程匹配规则,由 ParseProcessMatcher 解析
+type ProcessMatcher struct {
+	src   string
+	value func(Connection) string
+	match func(string) bool
+}
+
+// 进程匹配规则可以匹配的字段,默认为进程名
+var processFields = map[string]func(Connection) string{
+	"name":    func(c Connection) string { return c.ProcessName },
+	"exe":     func(c Connection) string { return c.Exe },
+	"cmdline": func(c Connection) string { return c.Cmdline },
+}
+
+// ParseProcessMatcher 解析进程匹配规则: [name:|exe:|cmdline:]模式。
+// 模式以 re: 开头时为正则表达式(部分匹配,区分大小写);包含 * 或 ? 时为通配符,
+// * 匹配任意字符(包括 /);否则为完整匹配。通配符和完整匹配不区分大小写。
+// 例如 sshd、chrome*、exe:/usr/sbin/*、cmdline:*--config=/etc/*、re:^kworker/
+func ParseProcessMatcher(s string) (ProcessMatcher, error) {
+	s = strings.TrimSpace(s)
+	m := ProcessMatcher{src: s, value: processFields["name"]}
+	pattern := s
+	if field, rest, ok := strings.Cut(s, ":"); ok && processFields[field] != nil {
+		m.value = processFields[field]
+		pattern = rest
+	}
+
+	switch {
+	case strings.HasPrefix(pattern, "re:"):
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, "re:"))
+		if err != nil {
+			return ProcessMatcher{}, fmt.Errorf("进程匹配规则 %q: 无效的正则表达式: %v", s, err)
+		}
+		m.match = re.MatchString
+	case strings.ContainsAny(pattern, "*?"):
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		m.match = regexp.MustCompile("(?is)^" + expr + "$").MatchString
+	case pattern == "":
+		return ProcessMatcher{}, fmt.Errorf("进程匹配规则 %q 为空", s)
+	default:
+		m.match = func(v string) bool { return strings.EqualFold(v, pattern) }
+	}
+	return m, nil
+}
+
+// ParseProcessMatchers 解析进程匹配规则列表
+func ParseProcessMatchers(list []string) ([]ProcessMatcher, error) {
+	matchers := make([]ProcessMatcher, 0, len(list))
+	for _, s := range list {
+		m, err := ParseProcessMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// Match 连接所属进程是否匹配
+func (m ProcessMatcher) Match(c Connection) bool {
+	return m.match(m.value(c))
+}
+
+func (m ProcessMatcher) String() string {
+	return m.src
+}
+
+// processesMatch 连接所属进程是否匹配任一规则
+func processesMatch(matchers []ProcessMatcher, c Connection) bool {
+	for _, m := range matchers {
+		if m.Match(c) {
+			return true
+		}
+	}
+	return false
+}
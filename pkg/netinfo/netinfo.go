@@ -4,8 +4,17 @@ import (
 	"fmt"
 	"github.com/shirou/gopsutil/v3/net"
 	"github.com/shirou/gopsutil/v3/process"
+	"net/netip"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
+)
+
+// 地址族
+const (
+	FamilyIPv4 = "IPv4"
+	FamilyIPv6 = "IPv6"
 )
 
 // 跨平台套接字类型常量
@@ -15,19 +24,75 @@ const (
 )
 
 type Connection struct {
-	LocalAddr   string // 本地地址(IP:Port)
-	RemoteAddr  string // 远程地址(IP:Port)
-	Protocol    string // 协议类型(TCP/UDP)
-	Status      string // 连接状态
-	PID         int32  // 进程ID
-	ProcessName string // 进程名称
+	LocalAddr   netip.AddrPort `json:"local_addr"`    // 本地地址
+	RemoteAddr  netip.AddrPort `json:"remote_addr"`   // 远程地址(未连接时为对端未指定地址)
+	Family      string         `json:"family"`        // 地址族(IPv4/IPv6)
+	Protocol    string         `json:"protocol"`      // 协议类型(TCP/UDP)
+	Status      string         `json:"status"`        // 连接状态
+	PID         int32          `json:"pid"`           // 进程ID
+	ProcessName string         `json:"process_name"`  // 进程名称
+	Exe         string         `json:"exe,omitempty"` // 进程可执行文件路径
+	Cmdline     string         `json:"-"`             // 进程命令行,可能包含敏感信息,不写入快照和录制文件
+	UID         uint32         `json:"uid"`           // 套接字所属用户(netlink/procfs后端提供)
+	Inode       uint64         `json:"inode"`         // 套接字inode(netlink/procfs后端提供)
+	RxQueue     uint32         `json:"rx_queue"`      // 接收队列字节数(netlink/procfs后端提供)
+	TxQueue     uint32         `json:"tx_queue"`      // 发送队列字节数(netlink/procfs后端提供)
 }
 
+// TCP状态名称,下标为内核中的状态码(include/net/tcp_states.h),名称与gopsutil保持一致
+var tcpStateNames = [...]string{
+	1:  "ESTABLISHED",
+	2:  "SYN_SENT",
+	3:  "SYN_RECV",
+	4:  "FIN_WAIT1",
+	5:  "FIN_WAIT2",
+	6:  "TIME_WAIT",
+	7:  "CLOSE",
+	8:  "CLOSE_WAIT",
+	9:  "LAST_ACK",
+	10: "LISTEN",
+	11: "CLOSING",
+}
+
+// tcpStateName 根据内核状态码返回状态名称
+func tcpStateName(state uint8) string {
+	if int(state) < len(tcpStateNames) && tcpStateNames[state] != "" {
+		return tcpStateNames[state]
+	}
+	return fmt.Sprintf("UNKNOWN-%d", state)
+}
+
+// Snapshot 某一时刻采集到的全部连接,同一轮检测中的所有消费者共享同一份快照
+type Snapshot struct {
+	Timestamp   time.Time    `json:"timestamp"`   // 采集时间
+	Connections []Connection `json:"connections"` // 连接列表
+}
+
+// ConnectionFilter 连接过滤条件。
+//
+// 先检查排除规则(Exclude*),匹配任一排除规则的连接总是被过滤;其余连接必须满足每一类已设置的
+// 包含条件,同一类中满足任一取值即可。未设置(为空)的条件不做限制
 type ConnectionFilter struct {
-	ProcessName string
-	PIDs        []int32
-	Protocols   []string
-	RemoteIP    string
+	Processes []ProcessMatcher // 进程名、可执行文件路径或命令行
+	PIDs      []int32
+	Protocols []string
+	Families  []string    // 地址族: ipv4, ipv6
+	States    []string    // 连接状态,例如 LISTEN, ESTABLISHED
+	Expr      *FilterExpr // 过滤表达式,与其他条件同时满足才保留(nil表示不使用)
+
+	// 地址和端口。监听端口和未连接的UDP套接字的远程地址为 0.0.0.0:0 或 [::]:0,视为没有远程地址:
+	// 设置远程包含条件时不会保留,远程排除条件对其不生效
+	RemoteNets  []netip.Prefix
+	LocalNets   []netip.Prefix
+	RemotePorts []PortRange
+	LocalPorts  []PortRange
+
+	// 排除规则
+	ExcludeProcesses   []ProcessMatcher
+	ExcludeRemoteNets  []netip.Prefix
+	ExcludeLocalNets   []netip.Prefix
+	ExcludeRemotePorts []PortRange
+	ExcludeLocalPorts  []PortRange
 }
 
 // 精准协议判断（跨平台兼容）
@@ -42,7 +107,13 @@ func getProtocol(c net.ConnectionStat) string {
 	return fmt.Sprintf("UNKNOWN-%d", c.Type)
 }
 
+// ShouldFilter 连接是否应被过滤(不显示、不监控)
 func (f *ConnectionFilter) ShouldFilter(conn Connection) bool {
+	// 排除规则优先
+	if f.excluded(conn) {
+		return true
+	}
+
 	// 检查协议
 	if len(f.Protocols) > 0 {
 		found := false
@@ -57,8 +128,22 @@ func (f *ConnectionFilter) ShouldFilter(conn Connection) bool {
 		}
 	}
 
-	// 检查进程名
-	if f.ProcessName != "" && !strings.EqualFold(conn.ProcessName, f.ProcessName) {
+	// 检查地址族
+	if len(f.Families) > 0 {
+		found := false
+		for _, family := range f.Families {
+			if strings.EqualFold(family, conn.Family) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+
+	// 检查进程
+	if len(f.Processes) > 0 && !processesMatch(f.Processes, conn) {
 		return true
 	}
 
@@ -76,16 +161,115 @@ func (f *ConnectionFilter) ShouldFilter(conn Connection) bool {
 		}
 	}
 
-	// 检查远程IP
-	if f.RemoteIP != "" && !strings.Contains(conn.RemoteAddr, f.RemoteIP) {
+	// 检查地址和端口
+	if f.shouldFilterAddr(conn) {
 		return true
 	}
 
+	// 检查连接状态
+	if len(f.States) > 0 {
+		found := false
+		for _, state := range f.States {
+			if strings.EqualFold(state, conn.Status) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+
+	// 检查过滤表达式
+	if f.Expr != nil && !f.Expr.Match(conn) {
+		return true
+	}
+
+	return false
+}
+
+// excluded 连接是否匹配任一排除规则
+func (f *ConnectionFilter) excluded(conn Connection) bool {
+	local, remote := conn.LocalAddr, conn.RemoteAddr
+	if processesMatch(f.ExcludeProcesses, conn) ||
+		prefixesContain(f.ExcludeLocalNets, local.Addr()) || portsContain(f.ExcludeLocalPorts, local.Port()) {
+		return true
+	}
+	return hasRemote(conn) &&
+		(prefixesContain(f.ExcludeRemoteNets, remote.Addr()) || portsContain(f.ExcludeRemotePorts, remote.Port()))
+}
+
+// hasRemote 连接是否有远程地址,监听端口和未连接的UDP套接字的远程地址为未指定地址且端口为0
+func hasRemote(conn Connection) bool {
+	remote := conn.RemoteAddr
+	return remote.IsValid() && !(remote.Addr().IsUnspecified() && remote.Port() == 0)
+}
+
+// shouldFilterAddr 按网段和端口检查本地、远程地址
+func (f *ConnectionFilter) shouldFilterAddr(conn Connection) bool {
+	local, remote := conn.LocalAddr, conn.RemoteAddr
+	if len(f.LocalNets) > 0 && !prefixesContain(f.LocalNets, local.Addr()) {
+		return true
+	}
+	if (len(f.RemoteNets) > 0 || len(f.RemotePorts) > 0) && !hasRemote(conn) {
+		return true
+	}
+	if len(f.RemoteNets) > 0 && !prefixesContain(f.RemoteNets, remote.Addr()) {
+		return true
+	}
+	if len(f.LocalPorts) > 0 && !portsContain(f.LocalPorts, local.Port()) {
+		return true
+	}
+	if len(f.RemotePorts) > 0 && !portsContain(f.RemotePorts, remote.Port()) {
+		return true
+	}
 	return false
 }
 
+// FormatAddr 格式化地址,IPv6地址带方括号(如 [::1]:443),无效地址返回空字符串
+func FormatAddr(addr netip.AddrPort) string {
+	if !addr.IsValid() {
+		return ""
+	}
+	return addr.String()
+}
+
+// newAddrPort 由原始IP字节和端口构造地址,4字节为IPv4,16字节为IPv6
+func newAddrPort(ip []byte, port uint32) netip.AddrPort {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.AddrPort{}
+	}
+	return netip.AddrPortFrom(addr, uint16(port))
+}
+
+// parseAddrPort 由IP字符串和端口构造地址,支持带zone的IPv6地址
+func parseAddrPort(ip string, port uint32) netip.AddrPort {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.AddrPort{}
+	}
+	return netip.AddrPortFrom(addr, uint16(port))
+}
+
+// getFamily 判断gopsutil连接的地址族
+func getFamily(c net.ConnectionStat, local netip.AddrPort) string {
+	switch c.Family {
+	case syscall.AF_INET:
+		return FamilyIPv4
+	case syscall.AF_INET6:
+		return FamilyIPv6
+	}
+	if local.Addr().Is6() {
+		return FamilyIPv6
+	}
+	return FamilyIPv4
+}
+
+// GetConnections 通过gopsutil获取全部连接,并补充所属进程的信息
 func GetConnections() ([]Connection, error) {
-	conns, err := net.Connections("all")
+	// 只获取TCP/UDP套接字,"all" 会把Unix域套接字也混进来
+	conns, err := net.Connections("inet")
 	if err != nil {
 		return nil, fmt.Errorf("获取连接信息失败: %w", err)
 	}
@@ -93,29 +277,114 @@ func GetConnections() ([]Connection, error) {
 	var result []Connection
 	for _, c := range conns {
 		protocol := getProtocol(c)
-		localAddr := fmt.Sprintf("%s:%d", c.Laddr.IP, c.Laddr.Port)
-		remoteAddr := fmt.Sprintf("%s:%d", c.Raddr.IP, c.Raddr.Port)
+		localAddr := parseAddrPort(c.Laddr.IP, c.Laddr.Port)
+		remoteAddr := parseAddrPort(c.Raddr.IP, c.Raddr.Port)
 
 		conn := Connection{
 			LocalAddr:   localAddr,
 			RemoteAddr:  remoteAddr,
+			Family:      getFamily(c, localAddr),
 			Protocol:    protocol,
 			Status:      c.Status,
 			PID:         c.Pid,
 			ProcessName: "",
 		}
 
-		// 获取进程名,增加错误处理
-		if c.Pid > 0 {
-			p, err := process.NewProcess(c.Pid)
-			if err == nil {
-				if name, err := p.Name(); err == nil {
-					conn.ProcessName = name
+		result = append(result, conn)
+	}
+	gopsutilProcs.fill(result, processStartTime, lookupProcess)
+	return result, nil
+}
+
+// gopsutilProcs gopsutil后端的进程信息缓存
+var gopsutilProcs processCache
+
+// processInfo 连接所属进程的信息
+type processInfo struct {
+	name    string
+	exe     string
+	cmdline string
+}
+
+func (p processInfo) apply(c *Connection) {
+	c.ProcessName = p.name
+	c.Exe = p.exe
+	c.Cmdline = p.cmdline
+}
+
+// processKey 进程的唯一标识,PID会被复用,加上启动时间才能区分不同的进程
+type processKey struct {
+	pid   int32
+	start uint64
+}
+
+// processCache 跨采集周期缓存进程信息。每轮只查询进程的启动时间,
+// 新出现的进程才读取进程名、可执行文件和命令行;本轮未出现的进程(通常已退出)从缓存中清除
+type processCache struct {
+	mu      sync.Mutex
+	entries map[processKey]processInfo
+}
+
+// fill 为有所属进程的连接补充进程信息。startTime 查询进程启动时间,
+// 查询失败时直接调用 load 读取且不缓存
+func (pc *processCache) fill(conns []Connection, startTime func(pid int32) (uint64, bool), load func(pid int32) processInfo) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	procs := make(map[int32]processInfo)
+	next := make(map[processKey]processInfo, len(pc.entries))
+	for i := range conns {
+		pid := conns[i].PID
+		if pid <= 0 {
+			continue
+		}
+		info, done := procs[pid]
+		if !done {
+			if start, ok := startTime(pid); ok {
+				key := processKey{pid: pid, start: start}
+				var cached bool
+				if info, cached = pc.entries[key]; !cached {
+					info = load(pid)
 				}
+				next[key] = info
+			} else {
+				info = load(pid)
 			}
+			procs[pid] = info
 		}
+		info.apply(&conns[i])
+	}
+	pc.entries = next
+}
 
-		result = append(result, conn)
+// processStartTime 通过gopsutil查询进程启动时间(毫秒时间戳)
+func processStartTime(pid int32) (uint64, bool) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return 0, false
 	}
-	return result, nil
-}
\ No newline at end of file
+	created, err := p.CreateTime()
+	if err != nil {
+		return 0, false
+	}
+	return uint64(created), true
+}
+
+// lookupProcess 通过gopsutil查询进程名、可执行文件和命令行,查询失败的项留空
+func lookupProcess(pid int32) processInfo {
+	var info processInfo
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return info
+	}
+	if name, err := p.Name(); err == nil {
+		info.name = name
+	}
+	if exe, err := p.Exe(); err == nil {
+		info.exe = exe
+	}
+	if cmdline, err := p.Cmdline(); err == nil {
+		info.cmdline = cmdline
+	}
+	return info
+}
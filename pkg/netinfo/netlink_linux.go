@@ -0,0 +1,225 @@
+//go:build linux
+
+package netinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// sock_diag 相关常量(include/uapi/linux/sock_diag.h, inet_diag.h)
+const (
+	netlinkSockDiag  = 4  // NETLINK_SOCK_DIAG
+	sockDiagByFamily = 20 // SOCK_DIAG_BY_FAMILY
+
+	inetDiagReqV2Len = 56 // sizeof(struct inet_diag_req_v2)
+	inetDiagMsgLen   = 72 // sizeof(struct inet_diag_msg)
+
+	netlinkRecvBufSize = 32 * 1024
+)
+
+// NetlinkCollector 通过 NETLINK_SOCK_DIAG(inet_diag) 枚举套接字,
+// TCP状态过滤交给内核完成,只有匹配的套接字才会被返回到用户态
+type NetlinkCollector struct {
+	tcpStates uint32 // TCP状态位掩码,第n位对应状态码n
+	procfs    *ProcfsCollector
+}
+
+// NewNetlinkCollector 创建netlink采集器,states为需要的TCP状态名称(留空表示全部)。
+// UDP套接字没有连接状态,始终全部返回
+func NewNetlinkCollector(states ...string) *NetlinkCollector {
+	return &NetlinkCollector{
+		tcpStates: tcpStateMask(states),
+		procfs:    NewProcfsCollector("/proc"),
+	}
+}
+
+// tcpStateMask 将状态名称转换为内核使用的位掩码
+func tcpStateMask(states []string) uint32 {
+	if len(states) == 0 {
+		return ^uint32(0)
+	}
+	var mask uint32
+	for _, s := range states {
+		for code, name := range tcpStateNames {
+			if name != "" && strings.EqualFold(name, s) {
+				mask |= 1 << uint(code)
+			}
+		}
+	}
+	return mask
+}
+
+// Probe 检查当前环境是否可以使用netlink
+func (c *NetlinkCollector) Probe() error {
+	fd, err := openSockDiag()
+	if err != nil {
+		return err
+	}
+	syscall.Close(fd)
+	return nil
+}
+
+// Connections 按配置的状态采集全部连接
+func (c *NetlinkCollector) Connections() ([]Connection, error) {
+	return c.collect(c.tcpStates)
+}
+
+func (c *NetlinkCollector) collect(tcpStates uint32) ([]Connection, error) {
+	fd, err := openSockDiag()
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+
+	queries := []struct {
+		family   uint8
+		protocol uint8
+		states   uint32
+	}{
+		{syscall.AF_INET, syscall.IPPROTO_TCP, tcpStates},
+		{syscall.AF_INET6, syscall.IPPROTO_TCP, tcpStates},
+		{syscall.AF_INET, syscall.IPPROTO_UDP, ^uint32(0)},
+		{syscall.AF_INET6, syscall.IPPROTO_UDP, ^uint32(0)},
+	}
+
+	var result []Connection
+	for seq, q := range queries {
+		if q.states == 0 {
+			continue
+		}
+		conns, err := dumpSockDiag(fd, uint32(seq+1), q.family, q.protocol, q.states)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, conns...)
+	}
+
+	// 通过 /proc/*/fd 补充进程信息
+	owners := c.procfs.socketOwners()
+	for i := range result {
+		if pid, ok := owners[result[i].Inode]; ok {
+			result[i].PID = pid
+		}
+	}
+	c.procfs.procs.fill(result, c.procfs.startTime, c.procfs.processInfo)
+	return result, nil
+}
+
+// openSockDiag 创建 NETLINK_SOCK_DIAG 套接字
+func openSockDiag() (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkSockDiag)
+	if err != nil {
+		return -1, fmt.Errorf("%w: %v", ErrNetlinkUnavailable, err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("%w: %v", ErrNetlinkUnavailable, err)
+	}
+	return fd, nil
+}
+
+// dumpSockDiag 发送一次 SOCK_DIAG_BY_FAMILY 转储请求并读取全部响应
+func dumpSockDiag(fd int, seq uint32, family, protocol uint8, states uint32) ([]Connection, error) {
+	req := make([]byte, syscall.NLMSG_HDRLEN+inetDiagReqV2Len)
+
+	// struct nlmsghdr
+	binary.NativeEndian.PutUint32(req[0:], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:], sockDiagByFamily)
+	binary.NativeEndian.PutUint16(req[6:], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(req[8:], seq)
+
+	// struct inet_diag_req_v2,其中 inet_diag_sockid 全部为0表示不按地址过滤
+	body := req[syscall.NLMSG_HDRLEN:]
+	body[0] = family
+	body[1] = protocol
+	binary.NativeEndian.PutUint32(body[4:], states)
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("发送sock_diag请求失败: %w", err)
+	}
+
+	protoName := "TCP"
+	if protocol == syscall.IPPROTO_UDP {
+		protoName = "UDP"
+	}
+
+	var result []Connection
+	buf := make([]byte, netlinkRecvBufSize)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("读取sock_diag响应失败: %w", err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("解析sock_diag响应失败: %w", err)
+		}
+
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue
+			}
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return result, nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := int32(binary.NativeEndian.Uint32(m.Data)); errno != 0 {
+						return nil, fmt.Errorf("sock_diag请求失败: %w", syscall.Errno(-errno))
+					}
+				}
+				return result, nil
+			case sockDiagByFamily:
+				if conn, ok := parseInetDiagMsg(m.Data, protoName); ok {
+					result = append(result, conn)
+				}
+			}
+		}
+	}
+}
+
+// parseInetDiagMsg 解析 struct inet_diag_msg
+func parseInetDiagMsg(data []byte, protocol string) (Connection, bool) {
+	if len(data) < inetDiagMsgLen {
+		return Connection{}, false
+	}
+
+	family := data[0]
+	state := data[1]
+
+	// struct inet_diag_sockid: 端口为网络字节序,地址按网络字节序存放
+	sport := uint32(binary.BigEndian.Uint16(data[4:]))
+	dport := uint32(binary.BigEndian.Uint16(data[6:]))
+	src, dst := diagIP(family, data[8:24]), diagIP(family, data[24:40])
+
+	conn := Connection{
+		LocalAddr:  newAddrPort(src, sport),
+		RemoteAddr: newAddrPort(dst, dport),
+		Family:     FamilyIPv4,
+		Protocol:   protocol,
+		Status:     "NONE",
+		RxQueue:    binary.NativeEndian.Uint32(data[56:]),
+		TxQueue:    binary.NativeEndian.Uint32(data[60:]),
+		UID:        binary.NativeEndian.Uint32(data[64:]),
+		Inode:      uint64(binary.NativeEndian.Uint32(data[68:])),
+	}
+	if family == syscall.AF_INET6 {
+		conn.Family = FamilyIPv6
+	}
+	if protocol == "TCP" {
+		conn.Status = tcpStateName(state)
+	}
+	return conn, true
+}
+
+func diagIP(family uint8, raw []byte) net.IP {
+	if family == syscall.AF_INET {
+		return net.IP(append([]byte(nil), raw[:net.IPv4len]...))
+	}
+	return net.IP(append([]byte(nil), raw[:net.IPv6len]...))
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package netinfo
+
+// NetlinkCollector 非Linux平台上的占位实现,所有调用都返回 ErrNetlinkUnavailable
+type NetlinkCollector struct{}
+
+func NewNetlinkCollector(states ...string) *NetlinkCollector {
+	return &NetlinkCollector{}
+}
+
+func (c *NetlinkCollector) Probe() error {
+	return ErrNetlinkUnavailable
+}
+
+func (c *NetlinkCollector) Connections() ([]Connection, error) {
+	return nil, ErrNetlinkUnavailable
+}
@@ -0,0 +1,298 @@
+//go:build linux
+
+package netinfo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procfsSupported 当前平台是否支持procfs采集
+const procfsSupported = true
+
+// procNetTable /proc/net 下的一张套接字表
+type procNetTable struct {
+	file     string
+	protocol string
+	ipv6     bool
+}
+
+var procNetTables = []procNetTable{
+	{file: "tcp", protocol: "TCP"},
+	{file: "tcp6", protocol: "TCP", ipv6: true},
+	{file: "udp", protocol: "UDP"},
+	{file: "udp6", protocol: "UDP", ipv6: true},
+}
+
+// procSocket /proc/net 表中解析出的一行
+type procSocket struct {
+	protocol   string
+	family     string
+	localIP    net.IP
+	localPort  uint32
+	remoteIP   net.IP
+	remotePort uint32
+	status     string
+	txQueue    uint32
+	rxQueue    uint32
+	uid        uint32
+	inode      uint64
+}
+
+// ProcfsCollector 直接解析 /proc/net/{tcp,tcp6,udp,udp6} 的采集器,
+// 每次采集只遍历一次 /proc/*/fd 建立 inode→PID 映射
+type ProcfsCollector struct {
+	Root  string // procfs根目录,通常为 /proc,测试时可指向伪造目录
+	procs processCache
+}
+
+func NewProcfsCollector(root string) *ProcfsCollector {
+	if root == "" {
+		root = "/proc"
+	}
+	return &ProcfsCollector{Root: root}
+}
+
+// Connections 采集全部TCP/UDP连接
+func (c *ProcfsCollector) Connections() ([]Connection, error) {
+	var sockets []procSocket
+	found := false
+	for _, t := range procNetTables {
+		entries, err := parseProcNetFile(filepath.Join(c.Root, "net", t.file), t.protocol, t.ipv6)
+		if err != nil {
+			// 内核未启用IPv6时 tcp6/udp6 不存在
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("解析 %s 失败: %w", t.file, err)
+		}
+		found = true
+		sockets = append(sockets, entries...)
+	}
+	if !found {
+		return nil, fmt.Errorf("未找到 %s 下的套接字表", filepath.Join(c.Root, "net"))
+	}
+
+	owners := c.socketOwners()
+
+	result := make([]Connection, 0, len(sockets))
+	for _, s := range sockets {
+		conn := Connection{
+			LocalAddr:  newAddrPort(s.localIP, s.localPort),
+			RemoteAddr: newAddrPort(s.remoteIP, s.remotePort),
+			Family:     s.family,
+			Protocol:   s.protocol,
+			Status:     s.status,
+			UID:        s.uid,
+			Inode:      s.inode,
+			RxQueue:    s.rxQueue,
+			TxQueue:    s.txQueue,
+		}
+
+		if pid, ok := owners[s.inode]; ok {
+			conn.PID = pid
+		}
+
+		result = append(result, conn)
+	}
+	c.procs.fill(result, c.startTime, c.processInfo)
+	return result, nil
+}
+
+// socketOwners 遍历 /proc/*/fd 建立套接字 inode → PID 映射
+func (c *ProcfsCollector) socketOwners() map[uint64]int32 {
+	owners := make(map[uint64]int32)
+
+	entries, err := os.ReadDir(c.Root)
+	if err != nil {
+		return owners
+	}
+
+	for _, e := range entries {
+		pid, err := strconv.ParseInt(e.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join(c.Root, e.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// 进程已退出或无权限访问
+			continue
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			inode, ok := parseSocketLink(link)
+			if !ok {
+				continue
+			}
+			if _, exists := owners[inode]; !exists {
+				owners[inode] = int32(pid)
+			}
+		}
+	}
+	return owners
+}
+
+// processInfo 读取 /proc/<pid> 下的 comm、exe 和 cmdline,读取失败(进程已退出或无权限)的项留空
+func (c *ProcfsCollector) processInfo(pid int32) processInfo {
+	dir := filepath.Join(c.Root, strconv.Itoa(int(pid)))
+	var info processInfo
+	if data, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		info.name = strings.TrimSpace(string(data))
+	}
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+		info.exe = strings.TrimSuffix(exe, " (deleted)")
+	}
+	// 参数之间以NUL分隔
+	if data, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		info.cmdline = strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+	}
+	return info
+}
+
+// startTime 读取 /proc/<pid>/stat 中的进程启动时间(第22个字段,单位为时钟周期)。
+// 进程名(第2个字段)可能包含空格和括号,因此从最后一个 ) 之后开始计数
+func (c *ProcfsCollector) startTime(pid int32) (uint64, bool) {
+	data, err := os.ReadFile(filepath.Join(c.Root, strconv.Itoa(int(pid)), "stat"))
+	if err != nil {
+		return 0, false
+	}
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return 0, false
+	}
+	// ) 之后从第3个字段(state)开始
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return 0, false
+	}
+	start, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+// parseSocketLink 解析形如 "socket:[12345]" 的fd链接
+func parseSocketLink(link string) (uint64, bool) {
+	if !strings.HasPrefix(link, "socket:[") || !strings.HasSuffix(link, "]") {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(link[len("socket:["):len(link)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return inode, true
+}
+
+// parseProcNetFile 解析 /proc/net/{tcp,udp}[6] 文件
+func parseProcNetFile(path, protocol string, ipv6 bool) ([]procSocket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var result []procSocket
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	// 跳过表头
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		localIP, localPort, err := parseProcAddr(fields[1], ipv6)
+		if err != nil {
+			return nil, err
+		}
+		remoteIP, remotePort, err := parseProcAddr(fields[2], ipv6)
+		if err != nil {
+			return nil, err
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的inode %q: %w", fields[9], err)
+		}
+		uid, _ := strconv.ParseUint(fields[7], 10, 32)
+
+		// tx_queue:rx_queue
+		var txQueue, rxQueue uint64
+		if tx, rx, ok := strings.Cut(fields[4], ":"); ok {
+			txQueue, _ = strconv.ParseUint(tx, 16, 32)
+			rxQueue, _ = strconv.ParseUint(rx, 16, 32)
+		}
+
+		status := "NONE"
+		if protocol == "TCP" {
+			st, err := strconv.ParseUint(fields[3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("无效的状态 %q: %w", fields[3], err)
+			}
+			status = tcpStateName(uint8(st))
+		}
+
+		family := FamilyIPv4
+		if ipv6 {
+			family = FamilyIPv6
+		}
+
+		result = append(result, procSocket{
+			protocol:   protocol,
+			family:     family,
+			localIP:    localIP,
+			localPort:  localPort,
+			remoteIP:   remoteIP,
+			remotePort: remotePort,
+			status:     status,
+			txQueue:    uint32(txQueue),
+			rxQueue:    uint32(rxQueue),
+			uid:        uint32(uid),
+			inode:      inode,
+		})
+	}
+	return result, scanner.Err()
+}
+
+// parseProcAddr 解析 "0100007F:0CEA" 形式的地址。
+// 内核按主机字节序逐个32位字输出地址,因此需要按字还原为网络字节序
+func parseProcAddr(s string, ipv6 bool) (net.IP, uint32, error) {
+	host, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("无效的地址 %q", s)
+	}
+
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("无效的端口 %q: %w", portHex, err)
+	}
+
+	raw, err := hex.DecodeString(host)
+	if err != nil {
+		return nil, 0, fmt.Errorf("无效的IP %q: %w", host, err)
+	}
+	if (ipv6 && len(raw) != net.IPv6len) || (!ipv6 && len(raw) != net.IPv4len) {
+		return nil, 0, fmt.Errorf("无效的IP长度 %q", host)
+	}
+
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.NativeEndian.PutUint32(ip[i:], binary.BigEndian.Uint32(raw[i:]))
+	}
+	return ip, uint32(port), nil
+}
@@ -0,0 +1,269 @@
+//go:build linux
+
+package netinfo
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const procNetHeader = "  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+// 伪造的 /proc/net 表,地址为小端主机上内核输出的格式(每个32位字按主机字节序)
+var procNetFixtures = map[string]string{
+	// 127.0.0.1:3306 LISTEN
+	"tcp": procNetHeader +
+		"   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 12345 1 0000000000000000 100 0 0 10 0\n",
+	// [2001:db8::1]:443 -> [2001:db8::2]:51000 ESTABLISHED,发送队列16字节、接收队列32字节;
+	// [::ffff:10.0.0.1]:8080 -> [::ffff:10.0.0.2]:40000 CLOSE_WAIT
+	"tcp6": procNetHeader +
+		"   0: B80D0120000000000000000001000000:01BB B80D0120000000000000000002000000:C738 01 00000010:00000020 00:00000000 00000000     0        0 22222 1 0000000000000000 20 4 30 10 -1\n" +
+		"   1: 0000000000000000FFFF00000100000A:1F90 0000000000000000FFFF00000200000A:9C40 08 00000000:00000000 00:00000000 00000000  1000        0 22223 1 0000000000000000 20 4 30 10 -1\n",
+	// 0.0.0.0:53,没有进程持有(例如所属进程无权限访问)
+	"udp": procNetHeader +
+		"   0: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 33333 2 0000000000000000 0\n",
+	// [::]:5353
+	"udp6": procNetHeader +
+		"   0: 00000000000000000000000000000000:14E9 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000   102        0 44444 2 0000000000000000 0\n",
+}
+
+// fakeProcess 伪造的 /proc/<pid>,fds 为 fd 编号到链接目标的映射
+type fakeProcess struct {
+	pid     string
+	comm    string
+	exe     string
+	cmdline string
+	start   string
+	fds     map[string]string
+}
+
+func writeFakeProcfs(t *testing.T, tables map[string]string, procs []fakeProcess) string {
+	t.Helper()
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink := func(target, path string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, content := range tables {
+		write(filepath.Join(root, "net", name), content)
+	}
+	for _, p := range procs {
+		dir := filepath.Join(root, p.pid)
+		write(filepath.Join(dir, "comm"), p.comm+"\n")
+		write(filepath.Join(dir, "cmdline"), p.cmdline)
+		// 进程名可能包含空格和括号,starttime 为 ) 之后的第20个字段
+		write(filepath.Join(dir, "stat"), p.pid+" ("+p.comm+") S"+strings.Repeat(" 0", 18)+" "+p.start+" 0 0\n")
+		symlink(p.exe, filepath.Join(dir, "exe"))
+		for fd, target := range p.fds {
+			symlink(target, filepath.Join(dir, "fd", fd))
+		}
+	}
+	return root
+}
+
+// skipIfBigEndian 伪造的地址按小端主机的格式书写
+func skipIfBigEndian(t *testing.T) {
+	t.Helper()
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("测试数据为小端主机格式")
+	}
+}
+
+func TestParseProcAddr(t *testing.T) {
+	skipIfBigEndian(t)
+
+	tests := []struct {
+		in   string
+		ipv6 bool
+		want string
+	}{
+		{"0100007F:0CEA", false, "127.0.0.1:3306"},
+		{"00000000:0035", false, "0.0.0.0:53"},
+		{"B80D0120000000000000000001000000:01BB", true, "[2001:db8::1]:443"},
+		{"000080FE00000000FF0E0002FEDCBA98:0016", true, "[fe80::200:eff:98ba:dcfe]:22"},
+		{"0000000000000000FFFF00000100000A:1F90", true, "[::ffff:10.0.0.1]:8080"},
+		{"00000000000000000000000001000000:0050", true, "[::1]:80"},
+	}
+	for _, tt := range tests {
+		ip, port, err := parseProcAddr(tt.in, tt.ipv6)
+		if err != nil {
+			t.Errorf("parseProcAddr(%q): %v", tt.in, err)
+			continue
+		}
+		if got := newAddrPort(ip, port).String(); got != tt.want {
+			t.Errorf("parseProcAddr(%q) = %s,期望 %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []struct {
+		s    string
+		ipv6 bool
+	}{
+		{"0100007F", false},                              // 缺少端口
+		{"0100007F:GGGG", false},                         // 端口不是十六进制
+		{"0100007X:0050", false},                         // IP不是十六进制
+		{"0100007F:0050", true},                          // IPv6表中出现IPv4长度的地址
+		{"00000000000000000000000001000000:0050", false}, // IPv4表中出现IPv6长度的地址
+	} {
+		if _, _, err := parseProcAddr(in.s, in.ipv6); err == nil {
+			t.Errorf("parseProcAddr(%q, %v) 期望返回错误", in.s, in.ipv6)
+		}
+	}
+}
+
+func TestParseProcNetFile(t *testing.T) {
+	skipIfBigEndian(t)
+
+	root := writeFakeProcfs(t, procNetFixtures, nil)
+	sockets, err := parseProcNetFile(filepath.Join(root, "net", "tcp6"), "TCP", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sockets) != 2 {
+		t.Fatalf("解析出 %d 个套接字,期望 2", len(sockets))
+	}
+	s := sockets[0]
+	if s.status != "ESTABLISHED" || s.family != FamilyIPv6 || s.inode != 22222 ||
+		s.txQueue != 16 || s.rxQueue != 32 || s.uid != 0 {
+		t.Errorf("第一行解析结果 %+v", s)
+	}
+	if s := sockets[1]; s.status != "CLOSE_WAIT" || s.uid != 1000 {
+		t.Errorf("第二行解析结果 %+v", s)
+	}
+
+	// UDP套接字没有连接状态
+	sockets, err = parseProcNetFile(filepath.Join(root, "net", "udp"), "UDP", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sockets) != 1 || sockets[0].status != "NONE" {
+		t.Errorf("UDP解析结果 %+v", sockets)
+	}
+
+	if _, err := parseProcNetFile(filepath.Join(root, "net", "missing"), "TCP", false); !os.IsNotExist(err) {
+		t.Errorf("文件不存在时期望 os.IsNotExist,得到 %v", err)
+	}
+}
+
+func TestProcfsCollectorConnections(t *testing.T) {
+	skipIfBigEndian(t)
+
+	root := writeFakeProcfs(t, procNetFixtures, []fakeProcess{
+		{
+			pid:     "100",
+			comm:    "mysqld",
+			exe:     "/usr/sbin/mysqld (deleted)",
+			cmdline: "/usr/sbin/mysqld\x00--port=3306\x00",
+			start:   "4242",
+			fds: map[string]string{
+				"0": "/dev/null",
+				"3": "socket:[12345]",
+				"4": "socket:[22222]",
+				"5": "pipe:[99999]",
+			},
+		},
+		{
+			pid:     "200",
+			comm:    "avahi-daemon",
+			exe:     "/usr/sbin/avahi-daemon",
+			cmdline: "avahi-daemon: running\x00",
+			start:   "5151",
+			fds: map[string]string{
+				"7": "socket:[44444]",
+				"8": "socket:[22223]",
+			},
+		},
+	})
+
+	conns, err := NewProcfsCollector(root).Connections()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mysqld := func(c Connection) Connection {
+		c.PID, c.ProcessName, c.Exe, c.Cmdline = 100, "mysqld", "/usr/sbin/mysqld", "/usr/sbin/mysqld --port=3306"
+		return c
+	}
+	avahi := func(c Connection) Connection {
+		c.PID, c.ProcessName, c.Exe, c.Cmdline = 200, "avahi-daemon", "/usr/sbin/avahi-daemon", "avahi-daemon: running"
+		return c
+	}
+	want := []Connection{
+		mysqld(Connection{
+			LocalAddr:  netip.MustParseAddrPort("127.0.0.1:3306"),
+			RemoteAddr: netip.MustParseAddrPort("0.0.0.0:0"),
+			Family:     FamilyIPv4, Protocol: "TCP", Status: "LISTEN",
+			UID: 999, Inode: 12345,
+		}),
+		mysqld(Connection{
+			LocalAddr:  netip.MustParseAddrPort("[2001:db8::1]:443"),
+			RemoteAddr: netip.MustParseAddrPort("[2001:db8::2]:51000"),
+			Family:     FamilyIPv6, Protocol: "TCP", Status: "ESTABLISHED",
+			Inode: 22222, TxQueue: 16, RxQueue: 32,
+		}),
+		avahi(Connection{
+			LocalAddr:  netip.MustParseAddrPort("[::ffff:10.0.0.1]:8080"),
+			RemoteAddr: netip.MustParseAddrPort("[::ffff:10.0.0.2]:40000"),
+			Family:     FamilyIPv6, Protocol: "TCP", Status: "CLOSE_WAIT",
+			UID: 1000, Inode: 22223,
+		}),
+		// 没有进程持有的套接字,进程信息留空
+		{
+			LocalAddr:  netip.MustParseAddrPort("0.0.0.0:53"),
+			RemoteAddr: netip.MustParseAddrPort("0.0.0.0:0"),
+			Family:     FamilyIPv4, Protocol: "UDP", Status: "NONE",
+			UID: 101, Inode: 33333,
+		},
+		avahi(Connection{
+			LocalAddr:  netip.MustParseAddrPort("[::]:5353"),
+			RemoteAddr: netip.MustParseAddrPort("[::]:0"),
+			Family:     FamilyIPv6, Protocol: "UDP", Status: "NONE",
+			UID: 102, Inode: 44444,
+		}),
+	}
+
+	if len(conns) != len(want) {
+		t.Fatalf("采集到 %d 个连接,期望 %d: %+v", len(conns), len(want), conns)
+	}
+	for i := range want {
+		if conns[i] != want[i] {
+			t.Errorf("第%d个连接\n得到 %+v\n期望 %+v", i, conns[i], want[i])
+		}
+	}
+}
+
+func TestProcfsCollectorMissingTables(t *testing.T) {
+	// 内核未启用IPv6时只有 tcp 和 udp
+	root := writeFakeProcfs(t, map[string]string{
+		"tcp": procNetHeader,
+		"udp": procNetHeader,
+	}, nil)
+	conns, err := NewProcfsCollector(root).Connections()
+	if err != nil || len(conns) != 0 {
+		t.Errorf("Connections() = %v, %v,期望空列表", conns, err)
+	}
+
+	// 一张表都没有时返回错误
+	if _, err := NewProcfsCollector(t.TempDir()).Connections(); err == nil {
+		t.Error("缺少全部套接字表时期望返回错误")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package netinfo
+
+import "errors"
+
+// procfsSupported 当前平台是否支持procfs采集
+const procfsSupported = false
+
+// ProcfsCollector 非Linux平台上的占位实现
+type ProcfsCollector struct {
+	Root string
+}
+
+func NewProcfsCollector(root string) *ProcfsCollector {
+	return &ProcfsCollector{Root: root}
+}
+
+func (c *ProcfsCollector) Connections() ([]Connection, error) {
+	return nil, errors.New("procfs采集仅支持Linux")
+}
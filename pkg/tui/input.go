@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// escapeKeys 常见终端的功能键序列
+var escapeKeys = map[string]string{
+	"\x1b[A":  "up",
+	"\x1b[B":  "down",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdn",
+	"\x1b[H":  "home",
+	"\x1b[1~": "home",
+	"\x1bOH":  "home",
+	"\x1b[F":  "end",
+	"\x1b[4~": "end",
+	"\x1bOF":  "end",
+}
+
+// readKeys 从原始模式的终端读取按键,读取结束时关闭 keys
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			for _, k := range parseKeys(buf[:n]) {
+				keys <- k
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseKeys 将一次读取到的字节拆分为按键: 功能键、控制键或单个字符
+func parseKeys(data []byte) []string {
+	var keys []string
+	s := string(data)
+	for len(s) > 0 {
+		switch c := s[0]; {
+		case c == 0x1b:
+			if len(s) == 1 {
+				keys = append(keys, "esc")
+				s = s[1:]
+				continue
+			}
+			// 未识别的序列整体丢弃: ESC [ 参数 结束字符
+			n := escapeLen(s)
+			if k, ok := escapeKeys[s[:n]]; ok {
+				keys = append(keys, k)
+			} else if n == 1 {
+				keys = append(keys, "esc")
+			}
+			s = s[n:]
+		case c == 0x03:
+			keys = append(keys, "ctrl-c")
+			s = s[1:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+			s = s[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+			s = s[1:]
+		case c == '\t':
+			keys = append(keys, "tab")
+			s = s[1:]
+		case c < ' ':
+			s = s[1:]
+		default:
+			r, size := utf8.DecodeRuneInString(s)
+			keys = append(keys, string(r))
+			s = s[size:]
+		}
+	}
+	return keys
+}
+
+// escapeLen 以 ESC 开头的控制序列长度
+func escapeLen(s string) int {
+	if len(s) < 2 {
+		return 1
+	}
+	switch s[1] {
+	case '[':
+		// CSI: 参数字节之后以 0x40-0x7e 结束
+		if i := strings.IndexFunc(s[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e }); i >= 0 {
+			return i + 3
+		}
+		return len(s)
+	case 'O':
+		if len(s) >= 3 {
+			return 3
+		}
+		return len(s)
+	}
+	return 1
+}
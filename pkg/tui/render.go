@@ -0,0 +1,437 @@
+package tui
+
+import (
+	"fmt"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/logger"
+	"netmonitor/pkg/netinfo"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 终端控制序列
+const (
+	escReverse  = "\x1b[7m"
+	escBold     = "\x1b[1m"
+	escReset    = "\x1b[0m"
+	escHome     = "\x1b[H"
+	escClearEOL = "\x1b[K"
+	escClearEOS = "\x1b[J"
+)
+
+const helpText = "q退出 Tab切换视图 s排序 r反向 ↑↓/PgUp/PgDn滚动 [ ]事件 p进程 x排除进程 o协议 i远程IP t状态 e表达式 c清除过滤"
+
+// 连接表的列,排序按列序号
+var connColumns = []string{"协议", "本地地址", "远程地址", "状态", "PID", "进程"}
+
+// 进程汇总的列
+var processColumns = []string{"连接", "PID", "进程", "监听", "已建立", "TCP", "UDP", "远程IP"}
+
+// processRow 一个进程的连接汇总
+type processRow struct {
+	PID         int32
+	Name        string
+	Total       int
+	Listen      int
+	Established int
+	TCP         int
+	UDP         int
+	Remotes     int // 不同远程IP的数量
+}
+
+// aggregateProcesses 按进程汇总连接
+func aggregateProcesses(conns []netinfo.Connection) []processRow {
+	rows := make(map[int32]*processRow)
+	remotes := make(map[int32]map[string]bool)
+	for _, c := range conns {
+		row := rows[c.PID]
+		if row == nil {
+			row = &processRow{PID: c.PID, Name: c.ProcessName}
+			rows[c.PID] = row
+			remotes[c.PID] = make(map[string]bool)
+		}
+		row.Total++
+		switch c.Status {
+		case "LISTEN":
+			row.Listen++
+		case "ESTABLISHED":
+			row.Established++
+		}
+		switch c.Protocol {
+		case "TCP":
+			row.TCP++
+		case "UDP":
+			row.UDP++
+		}
+		if c.RemoteAddr.IsValid() && !c.RemoteAddr.Addr().IsUnspecified() {
+			remotes[c.PID][c.RemoteAddr.Addr().String()] = true
+		}
+	}
+
+	result := make([]processRow, 0, len(rows))
+	for pid, row := range rows {
+		row.Remotes = len(remotes[pid])
+		result = append(result, *row)
+	}
+	return result
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// sortConnections 按列排序,相同时按本地地址和远程地址排序
+func sortConnections(conns []netinfo.Connection, col int, desc bool) {
+	sort.SliceStable(conns, func(i, j int) bool {
+		a, b := conns[i], conns[j]
+		var c int
+		switch col {
+		case 0:
+			c = strings.Compare(a.Protocol, b.Protocol)
+		case 2:
+			c = a.RemoteAddr.Compare(b.RemoteAddr)
+		case 3:
+			c = strings.Compare(a.Status, b.Status)
+		case 4:
+			c = compareInt(int(a.PID), int(b.PID))
+		case 5:
+			c = strings.Compare(strings.ToLower(a.ProcessName), strings.ToLower(b.ProcessName))
+		}
+		if c == 0 {
+			c = a.LocalAddr.Compare(b.LocalAddr)
+		}
+		if c == 0 {
+			c = a.RemoteAddr.Compare(b.RemoteAddr)
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// sortProcesses 按列排序,相同时按PID排序
+func sortProcesses(rows []processRow, col int, desc bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		var c int
+		switch col {
+		case 0:
+			c = compareInt(a.Total, b.Total)
+		case 2:
+			c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case 3:
+			c = compareInt(a.Listen, b.Listen)
+		case 4:
+			c = compareInt(a.Established, b.Established)
+		case 5:
+			c = compareInt(a.TCP, b.TCP)
+		case 6:
+			c = compareInt(a.UDP, b.UDP)
+		case 7:
+			c = compareInt(a.Remotes, b.Remotes)
+		}
+		if desc {
+			c = -c
+		}
+		if c == 0 {
+			c = compareInt(int(a.PID), int(b.PID))
+		}
+		return c < 0
+	})
+}
+
+// eventRows 事件窗格的行数,终端太小时不显示事件窗格
+func (a *App) eventRows() int {
+	if a.height < 12 {
+		return 0
+	}
+	return (a.height - 5) / 3
+}
+
+// tableRows 表格数据区的行数: 去掉标题、过滤条件、表头、事件窗格和底部状态栏
+func (a *App) tableRows() int {
+	rows := a.height - 4
+	if n := a.eventRows(); n > 0 {
+		rows -= n + 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+func (a *App) rowCount() int {
+	if a.view == viewProcesses {
+		return len(a.procs)
+	}
+	return len(a.conns)
+}
+
+// render 生成整屏内容,每次从左上角重绘
+func (a *App) render() string {
+	w := a.width
+	var lines []string
+
+	// 标题栏
+	established, listening := 0, 0
+	for _, c := range a.conns {
+		switch c.Status {
+		case "ESTABLISHED":
+			established++
+		case "LISTEN":
+			listening++
+		}
+	}
+	title := fmt.Sprintf(" NetMonitor  连接 %d  已建立 %d  监听 %d  进程 %d",
+		len(a.conns), established, listening, len(a.procs))
+	if a.opts.Stats != nil {
+		c := a.opts.Stats.GetCounters()
+		title += fmt.Sprintf("  |  新建 %d  关闭 %d  新监听 %d  告警 %d",
+			c.NewConnections, c.ClosedConnections, c.NewListeners, c.Alerts)
+	}
+	if a.snap != nil {
+		title += "  " + a.snap.Timestamp.Format("15:04:05")
+	}
+	lines = append(lines, escReverse+fit(title, w)+escReset)
+
+	// 过滤条件和排序
+	columns := connColumns
+	if a.view == viewProcesses {
+		columns = processColumns
+	}
+	order := "↑"
+	if a.sortDesc[a.view] {
+		order = "↓"
+	}
+	lines = append(lines, fit(fmt.Sprintf(" 过滤: %s  排序: %s%s",
+		filterSummary(a.opts.Filter), columns[a.sortCol[a.view]], order), w))
+
+	// 表格
+	rows := a.tableRows()
+	total := a.rowCount()
+	if a.offset > total-rows {
+		a.offset = total - rows
+	}
+	if a.offset < 0 {
+		a.offset = 0
+	}
+	var header string
+	var body []string
+	if a.view == viewProcesses {
+		header, body = a.processTable(w, rows)
+	} else {
+		header, body = a.connectionTable(w, rows)
+	}
+	lines = append(lines, escBold+escReverse+fit(header, w)+escReset)
+	for _, line := range body {
+		lines = append(lines, fit(line, w))
+	}
+	for i := len(body); i < rows; i++ {
+		lines = append(lines, "")
+	}
+
+	// 事件窗格
+	if n := a.eventRows(); n > 0 {
+		lines = append(lines, a.eventPane(w, n)...)
+	}
+
+	// 状态栏: 正在编辑的过滤条件、消息或按键说明
+	switch {
+	case a.prompt != nil:
+		lines = append(lines, fit(fmt.Sprintf("%s: %s_  (Enter确认 Esc取消)", fieldLabels[a.prompt.field], string(a.prompt.input)), w))
+	case a.message != "":
+		lines = append(lines, fit(a.message, w))
+	default:
+		lines = append(lines, fit(helpText, w))
+	}
+
+	if len(lines) > a.height {
+		lines = lines[:a.height]
+	}
+	return escHome + strings.Join(lines, escClearEOL+"\r\n") + escClearEOL + escClearEOS
+}
+
+// connectionTable 连接表的表头和可见行
+func (a *App) connectionTable(w, rows int) (string, []string) {
+	// 协议、状态、PID定宽,剩余宽度分给地址和进程名
+	const protoW, stateW, pidW = 5, 11, 7
+	addrW := (w - protoW - stateW - pidW - 5 - 16) / 2
+	if addrW < 15 {
+		addrW = 15
+	}
+	if addrW > 47 {
+		addrW = 47
+	}
+	procW := w - protoW - stateW - pidW - 2*addrW - 5
+	if procW < 8 {
+		procW = 8
+	}
+
+	row := func(cols ...string) string {
+		widths := []int{protoW, addrW, addrW, stateW, pidW, procW}
+		var b strings.Builder
+		for i, col := range cols {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(fit(col, widths[i]))
+		}
+		return b.String()
+	}
+
+	header := row(connColumns...)
+	var body []string
+	for i := a.offset; i < len(a.conns) && len(body) < rows; i++ {
+		c := a.conns[i]
+		pid := "-"
+		if c.PID > 0 {
+			pid = strconv.Itoa(int(c.PID))
+		}
+		body = append(body, row(c.Protocol, netinfo.FormatAddr(c.LocalAddr), remoteColumn(c), c.Status, pid, c.ProcessName))
+	}
+	return header, body
+}
+
+// remoteColumn 远程地址,未连接时显示为 *:*
+func remoteColumn(c netinfo.Connection) string {
+	if !c.RemoteAddr.IsValid() || c.RemoteAddr.Addr().IsUnspecified() && c.RemoteAddr.Port() == 0 {
+		return "*:*"
+	}
+	return netinfo.FormatAddr(c.RemoteAddr)
+}
+
+// processTable 进程汇总的表头和可见行
+func (a *App) processTable(w, rows int) (string, []string) {
+	widths := []int{6, 7, 24, 6, 6, 6, 6, 6}
+	row := func(cols ...string) string {
+		var b strings.Builder
+		for i, col := range cols {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(fit(col, widths[i]))
+		}
+		return b.String()
+	}
+
+	header := row(processColumns...)
+	var body []string
+	for i := a.offset; i < len(a.procs) && len(body) < rows; i++ {
+		p := a.procs[i]
+		pid := "-"
+		if p.PID > 0 {
+			pid = strconv.Itoa(int(p.PID))
+		}
+		body = append(body, row(strconv.Itoa(p.Total), pid, p.Name, strconv.Itoa(p.Listen),
+			strconv.Itoa(p.Established), strconv.Itoa(p.TCP), strconv.Itoa(p.UDP), strconv.Itoa(p.Remotes)))
+	}
+	return header, body
+}
+
+// eventPane 分隔线和最近的事件,最新的事件在最下面
+func (a *App) eventPane(w, n int) []string {
+	a.eventsMu.Lock()
+	events := a.events
+	a.eventsMu.Unlock()
+
+	if a.eventOffset > len(events)-n {
+		a.eventOffset = len(events) - n
+	}
+	if a.eventOffset < 0 {
+		a.eventOffset = 0
+	}
+	end := len(events) - a.eventOffset
+	start := end - n
+	if start < 0 {
+		start = 0
+	}
+
+	title := fmt.Sprintf("── 事件 (%d) ", len(events))
+	if a.eventOffset > 0 {
+		title += fmt.Sprintf("向前 %d 条 ", a.eventOffset)
+	}
+	lines := []string{fit(title+strings.Repeat("─", max(w-textWidth(title), 0)), w)}
+	for _, e := range events[start:end] {
+		text := fit(e.Timestamp.Format("15:04:05")+" "+logger.EventMessage(e), w)
+		lines = append(lines, eventColor(e.Kind)+text+escReset)
+	}
+	for len(lines) < n+1 {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// eventColor 与控制台日志相同的事件颜色
+func eventColor(kind event.Kind) string {
+	switch kind {
+	case event.ListenerOpened, event.ConnOpened:
+		return logger.ColorGreen
+	case event.ListenerClosed, event.ConnClosed:
+		return logger.ColorRed
+	case event.ListenerOwnerChanged, event.Alert:
+		return logger.ColorYellow
+	case event.StateChanged:
+		return logger.ColorPurple
+	}
+	return ""
+}
+
+// runeWidth 字符在终端中占用的列数,东亚宽字符占两列
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+// textWidth 文本在终端中占用的列数
+func textWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// fit 将文本截断或用空格补齐到恰好 w 列
+func fit(s string, w int) string {
+	if w <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if used+rw > w {
+			// 截断时用省略号占最后一列
+			out := []rune(b.String())
+			for used > w-1 && len(out) > 0 {
+				used -= runeWidth(out[len(out)-1])
+				out = out[:len(out)-1]
+			}
+			return string(out) + "…" + strings.Repeat(" ", w-1-used)
+		}
+		b.WriteRune(r)
+		used += rw
+	}
+	return b.String() + strings.Repeat(" ", w-used)
+}
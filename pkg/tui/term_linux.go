@@ -0,0 +1,71 @@
+//go:build linux
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// terminal 处于原始模式的控制终端,退出时恢复原来的设置
+type terminal struct {
+	fd  int
+	old syscall.Termios
+}
+
+// openTerminal 将标准输入切换到原始模式,并切换到备用屏幕、隐藏光标
+func openTerminal() (*terminal, error) {
+	t := &terminal{fd: int(os.Stdin.Fd())}
+	if err := ioctl(t.fd, syscall.TCGETS, unsafe.Pointer(&t.old)); err != nil {
+		return nil, fmt.Errorf("标准输入不是终端: %w", err)
+	}
+
+	// 关闭回显、行缓冲和信号键(Ctrl-C 作为普通按键读取),保留输出处理
+	raw := t.old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(t.fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, fmt.Errorf("设置终端原始模式失败: %w", err)
+	}
+
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	return t, nil
+}
+
+// restore 恢复光标、主屏幕和终端设置
+func (t *terminal) restore() {
+	os.Stdout.WriteString("\x1b[0m\x1b[?25h\x1b[?1049l")
+	ioctl(t.fd, syscall.TCSETS, unsafe.Pointer(&t.old))
+}
+
+// size 终端的列数和行数,无法获取时按80x24处理
+func (t *terminal) size() (int, int) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	if err := ioctl(int(os.Stdout.Fd()), syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// notifyResize 终端大小变化时通知 ch
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package tui
+
+import "os"
+
+// terminal 非Linux平台上的占位实现,openTerminal 总是返回 ErrUnsupported
+type terminal struct{}
+
+func openTerminal() (*terminal, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *terminal) restore() {}
+
+func (t *terminal) size() (int, int) {
+	return 80, 24
+}
+
+func notifyResize(ch chan<- os.Signal) {}
@@ -0,0 +1,439 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/monitor"
+	"netmonitor/pkg/netinfo"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrUnsupported 当前平台不支持TUI模式
+var ErrUnsupported = errors.New("TUI模式仅支持Linux终端")
+
+// DefaultMaxEvents 事件窗格默认保留的事件数
+const DefaultMaxEvents = 500
+
+// 视图
+const (
+	viewConnections = iota // 连接表
+	viewProcesses          // 按进程汇总
+)
+
+// 可编辑的过滤条件
+const (
+	fieldProcess = iota
+	fieldExcludeProcess
+	fieldProtocol
+	fieldRemote
+	fieldState
+	fieldExpr
+)
+
+var fieldLabels = map[int]string{
+	fieldProcess:        "进程(逗号分隔,支持 * 通配符和 re:、exe:、cmdline: 前缀)",
+	fieldExcludeProcess: "排除进程(逗号分隔)",
+	fieldProtocol:       "协议(逗号分隔)",
+	fieldRemote:         "远程IP或网段(逗号分隔)",
+	fieldState:          "状态(逗号分隔)",
+	fieldExpr:           "过滤表达式",
+}
+
+// Options TUI 的依赖,与 run 模式使用相同的采集器、监控器和统计
+type Options struct {
+	Collector  netinfo.Collector
+	Dispatcher *monitor.Dispatcher       // 检测变化并发布事件,事件通过 HandleEvent 回到事件窗格
+	Filter     *netinfo.ConnectionFilter // 与监控器共享,按键编辑时直接修改
+	Stats      *monitor.Stats
+	Interval   time.Duration
+	MaxEvents  int // 事件窗格保留的事件数(0表示 DefaultMaxEvents)
+}
+
+// prompt 正在编辑的过滤条件
+type prompt struct {
+	field int
+	input []rune
+}
+
+// App 全屏终端界面: 连接表或进程汇总、事件窗格和过滤条件编辑
+type App struct {
+	opts Options
+
+	snap     *netinfo.Snapshot
+	conns    []netinfo.Connection // 过滤、排序后的连接
+	procs    []processRow         // 按进程汇总后的行
+	finished bool                 // 回放已结束,不再采集
+
+	events   []event.Event
+	eventsMu sync.Mutex
+	redraw   chan struct{}
+
+	view        int
+	sortCol     [2]int
+	sortDesc    [2]bool
+	offset      int // 表格滚动位置
+	eventOffset int // 事件窗格距离最新事件的行数
+	prompt      *prompt
+	message     string
+	width       int
+	height      int
+}
+
+func New(opts Options) *App {
+	if opts.MaxEvents <= 0 {
+		opts.MaxEvents = DefaultMaxEvents
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Filter == nil {
+		opts.Filter = &netinfo.ConnectionFilter{}
+	}
+	a := &App{
+		opts:   opts,
+		redraw: make(chan struct{}, 1),
+	}
+	// 进程汇总默认按连接数从多到少排序
+	a.sortDesc[viewProcesses] = true
+	return a
+}
+
+// HandleEvent 将事件加入事件窗格,作为事件总线的订阅者使用
+func (a *App) HandleEvent(e event.Event) {
+	a.eventsMu.Lock()
+	a.events = append(a.events, e)
+	if len(a.events) > a.opts.MaxEvents {
+		a.events = a.events[len(a.events)-a.opts.MaxEvents:]
+	}
+	a.eventsMu.Unlock()
+
+	select {
+	case a.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// Run 以 baseline 为初始连接列表进入全屏界面,按 q 或 Ctrl-C 退出
+func (a *App) Run(baseline *netinfo.Snapshot) error {
+	term, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer term.restore()
+
+	a.setSnapshot(baseline)
+
+	keys := make(chan string, 16)
+	go readKeys(os.Stdin, keys)
+
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(resize)
+	defer signal.Stop(stop)
+
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	a.width, a.height = term.size()
+	for {
+		os.Stdout.WriteString(a.render())
+
+		select {
+		case k, ok := <-keys:
+			if !ok || a.handleKey(k) {
+				return nil
+			}
+		case <-resize:
+			a.width, a.height = term.size()
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			a.collect()
+		case <-a.redraw:
+		}
+	}
+}
+
+// collect 采集一次并驱动监控器,事件由总线异步送回 HandleEvent
+func (a *App) collect() {
+	if a.finished {
+		return
+	}
+	start := time.Now()
+	snap, err := a.opts.Collector.Collect()
+	if errors.Is(err, netinfo.ErrReplayExhausted) {
+		a.finished = true
+		a.message = "快照回放结束"
+		return
+	}
+	if a.opts.Stats != nil {
+		a.opts.Stats.RecordCollection(time.Since(start), err)
+	}
+	if err != nil {
+		a.message = fmt.Sprintf("连接信息采集错误: %v", err)
+		return
+	}
+
+	a.opts.Dispatcher.Process(snap)
+	if a.opts.Stats != nil {
+		a.opts.Stats.Update(snap)
+	}
+	a.setSnapshot(snap)
+}
+
+// setSnapshot 更新当前快照并重新过滤、排序
+func (a *App) setSnapshot(snap *netinfo.Snapshot) {
+	a.snap = snap
+	a.refresh()
+}
+
+func (a *App) refresh() {
+	a.conns = a.conns[:0]
+	if a.snap != nil {
+		for _, c := range a.snap.Connections {
+			if !a.opts.Filter.ShouldFilter(c) {
+				a.conns = append(a.conns, c)
+			}
+		}
+	}
+	sortConnections(a.conns, a.sortCol[viewConnections], a.sortDesc[viewConnections])
+	a.procs = aggregateProcesses(a.conns)
+	sortProcesses(a.procs, a.sortCol[viewProcesses], a.sortDesc[viewProcesses])
+}
+
+// handleKey 处理一次按键,返回true表示退出
+func (a *App) handleKey(k string) bool {
+	if k == "ctrl-c" {
+		return true
+	}
+	if a.prompt != nil {
+		a.editPrompt(k)
+		return false
+	}
+
+	a.message = ""
+	page := a.tableRows()
+	switch k {
+	case "q", "Q":
+		return true
+	case "tab":
+		a.view = 1 - a.view
+		a.offset = 0
+	case "s":
+		columns := len(connColumns)
+		if a.view == viewProcesses {
+			columns = len(processColumns)
+		}
+		a.sortCol[a.view] = (a.sortCol[a.view] + 1) % columns
+		a.refresh()
+	case "r":
+		a.sortDesc[a.view] = !a.sortDesc[a.view]
+		a.refresh()
+	case "up", "k":
+		a.offset--
+	case "down", "j":
+		a.offset++
+	case "pgup":
+		a.offset -= page
+	case "pgdn", " ":
+		a.offset += page
+	case "home", "g":
+		a.offset = 0
+	case "end", "G":
+		a.offset = a.rowCount()
+	case "[":
+		a.eventOffset++
+	case "]":
+		a.eventOffset--
+	case "p":
+		a.startPrompt(fieldProcess, joinValues(a.opts.Filter.Processes))
+	case "x":
+		a.startPrompt(fieldExcludeProcess, joinValues(a.opts.Filter.ExcludeProcesses))
+	case "o":
+		a.startPrompt(fieldProtocol, strings.Join(a.opts.Filter.Protocols, ","))
+	case "i":
+		a.startPrompt(fieldRemote, joinValues(a.opts.Filter.RemoteNets))
+	case "t":
+		a.startPrompt(fieldState, strings.Join(a.opts.Filter.States, ","))
+	case "e":
+		expr := ""
+		if a.opts.Filter.Expr != nil {
+			expr = a.opts.Filter.Expr.String()
+		}
+		a.startPrompt(fieldExpr, expr)
+	case "c":
+		a.opts.Filter.Processes = nil
+		a.opts.Filter.ExcludeProcesses = nil
+		a.opts.Filter.Protocols = nil
+		a.opts.Filter.RemoteNets = nil
+		a.opts.Filter.States = nil
+		a.opts.Filter.Expr = nil
+		a.filterChanged()
+	}
+	return false
+}
+
+func (a *App) startPrompt(field int, value string) {
+	a.prompt = &prompt{field: field, input: []rune(value)}
+}
+
+func (a *App) editPrompt(k string) {
+	p := a.prompt
+	switch k {
+	case "esc":
+		a.prompt = nil
+	case "enter":
+		a.prompt = nil
+		a.applyFilter(p.field, strings.TrimSpace(string(p.input)))
+	case "backspace":
+		if len(p.input) > 0 {
+			p.input = p.input[:len(p.input)-1]
+		}
+	default:
+		if r := []rune(k); len(r) == 1 && r[0] >= ' ' {
+			p.input = append(p.input, r[0])
+		}
+	}
+}
+
+// applyFilter 修改共享的过滤器
+func (a *App) applyFilter(field int, value string) {
+	f := a.opts.Filter
+	switch field {
+	case fieldProcess, fieldExcludeProcess:
+		matchers, err := netinfo.ParseProcessMatchers(splitProcesses(value))
+		if err != nil {
+			a.message = err.Error()
+			return
+		}
+		if field == fieldProcess {
+			f.Processes = matchers
+		} else {
+			f.ExcludeProcesses = matchers
+		}
+	case fieldProtocol:
+		f.Protocols = splitList(value, false)
+	case fieldRemote:
+		nets, err := netinfo.ParsePrefixes(splitList(value, false))
+		if err != nil {
+			a.message = err.Error()
+			return
+		}
+		f.RemoteNets = nets
+	case fieldState:
+		f.States = splitList(value, true)
+	case fieldExpr:
+		if value == "" {
+			f.Expr = nil
+			break
+		}
+		expr, err := netinfo.ParseFilterExpr(value)
+		if err != nil {
+			a.message = err.Error()
+			return
+		}
+		f.Expr = expr
+	}
+	a.filterChanged()
+}
+
+// filterChanged 过滤条件变化后让监控器重新判断已跟踪的连接,
+// 避免刚被过滤或取消过滤的连接在下一轮被当作新建或关闭
+func (a *App) filterChanged() {
+	a.opts.Dispatcher.SetFilter(a.opts.Filter)
+	a.offset = 0
+	a.refresh()
+	a.message = "过滤条件已更新: " + filterSummary(a.opts.Filter)
+}
+
+// splitList 拆分以逗号或空格分隔的列表
+func splitList(s string, upper bool) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if upper {
+			item = strings.ToUpper(item)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// splitProcesses 拆分以逗号分隔的进程匹配规则,命令行规则中可能含有空格
+func splitProcesses(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// filterSummary 过滤条件的简短描述
+func filterSummary(f *netinfo.ConnectionFilter) string {
+	var parts []string
+	if len(f.Processes) > 0 {
+		parts = append(parts, "进程="+joinValues(f.Processes))
+	}
+	if len(f.ExcludeProcesses) > 0 {
+		parts = append(parts, "排除进程="+joinValues(f.ExcludeProcesses))
+	}
+	if len(f.PIDs) > 0 {
+		pids := make([]string, len(f.PIDs))
+		for i, pid := range f.PIDs {
+			pids[i] = fmt.Sprint(pid)
+		}
+		parts = append(parts, "PID="+strings.Join(pids, ","))
+	}
+	if len(f.Protocols) > 0 {
+		parts = append(parts, "协议="+strings.Join(f.Protocols, ","))
+	}
+	if len(f.Families) > 0 {
+		parts = append(parts, "地址族="+strings.Join(f.Families, ","))
+	}
+	addrs := []struct {
+		label  string
+		values string
+	}{
+		{"远程网段=", joinValues(f.RemoteNets)},
+		{"排除远程网段=", joinValues(f.ExcludeRemoteNets)},
+		{"本地网段=", joinValues(f.LocalNets)},
+		{"排除本地网段=", joinValues(f.ExcludeLocalNets)},
+		{"远程端口=", joinValues(f.RemotePorts)},
+		{"排除远程端口=", joinValues(f.ExcludeRemotePorts)},
+		{"本地端口=", joinValues(f.LocalPorts)},
+		{"排除本地端口=", joinValues(f.ExcludeLocalPorts)},
+	}
+	for _, a := range addrs {
+		if a.values != "" {
+			parts = append(parts, a.label+a.values)
+		}
+	}
+	if len(f.States) > 0 {
+		parts = append(parts, "状态="+strings.Join(f.States, ","))
+	}
+	if f.Expr != nil {
+		parts = append(parts, "表达式="+f.Expr.String())
+	}
+	if len(parts) == 0 {
+		return "全部"
+	}
+	return strings.Join(parts, " ")
+}
+
+// joinValues 以逗号连接网段或端口范围
+func joinValues[T fmt.Stringer](values []T) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = v.String()
+	}
+	return strings.Join(items, ",")
+}
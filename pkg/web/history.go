@@ -0,0 +1,136 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"netmonitor/pkg/history"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryResponse /api/history 的响应
+type HistoryResponse struct {
+	Records   []history.Record `json:"records"`
+	Truncated bool             `json:"truncated"` // 结果超过 limit 被截断
+}
+
+// SetHistory 设置历史库,未设置时 /api/history 返回 404
+func (s *Server) SetHistory(store *history.Store) {
+	s.history = store
+}
+
+// parseTimeParam 解析时间参数: RFC3339、本地时间 "2006-01-02 15:04:05"、"2006-01-02T15:04" 或Unix秒
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的时间: %s", value)
+}
+
+// parseHistoryQuery 从请求参数构造查询条件
+func parseHistoryQuery(r *http.Request) (history.Query, error) {
+	params := r.URL.Query()
+	var q history.Query
+	var err error
+
+	if q.From, err = parseTimeParam(params.Get("from")); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTimeParam(params.Get("to")); err != nil {
+		return q, err
+	}
+	if kinds := params.Get("kind"); kinds != "" {
+		q.Kinds = strings.Split(kinds, ",")
+	}
+	q.Process = params.Get("process")
+	q.Protocol = params.Get("protocol")
+	if q.Remote, err = history.ParseRemote(params.Get("remote")); err != nil {
+		return q, err
+	}
+	if port := params.Get("port"); port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return q, fmt.Errorf("无效的端口: %s", port)
+		}
+		q.Port = uint16(n)
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, fmt.Errorf("无效的limit: %s", limit)
+		}
+	}
+	return q, nil
+}
+
+// handleHistory 按时间范围、进程、远程地址、端口和协议查询历史事件
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		http.Error(w, "History not enabled", http.StatusNotFound)
+		return
+	}
+
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, truncated, err := s.history.Events(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if records == nil {
+		records = []history.Record{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HistoryResponse{Records: records, Truncated: truncated})
+}
+
+// handleHistorySnapshot 返回不晚于 at 的最近一份快照,连接按同样的条件过滤
+func (s *Server) handleHistorySnapshot(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		http.Error(w, "History not enabled", http.StatusNotFound)
+		return
+	}
+
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		if at, err = parseTimeParam(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	snap, err := s.history.SnapshotAt(at, q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if snap == nil {
+		http.Error(w, "No snapshot before "+at.Format(time.RFC3339), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snap)
+}
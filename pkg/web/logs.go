@@ -0,0 +1,104 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"netmonitor/pkg/logger"
+	"strconv"
+	"strings"
+)
+
+// DefaultLogSearchLimit /api/logs/search 默认返回的最大条数
+const DefaultLogSearchLimit = 1000
+
+// LogSearchResponse /api/logs/search 的响应
+type LogSearchResponse struct {
+	Entries   []logger.Entry `json:"entries"`
+	Truncated bool           `json:"truncated"` // 结果超过 limit 被截断
+}
+
+// SetLogDirs 设置可搜索的日志目录,未设置时 /api/logs/search 返回 404
+func (s *Server) SetLogDirs(listenerDir, establishedDir string) {
+	s.logDirs = []logger.SearchDir{
+		{Source: logger.SourceListener, Dir: listenerDir},
+		{Source: logger.SourceEstablished, Dir: establishedDir},
+	}
+}
+
+// parseLogSearch 从请求参数构造日志搜索条件
+func (s *Server) parseLogSearch(r *http.Request) (logger.SearchOptions, error) {
+	params := r.URL.Query()
+	opts := logger.SearchOptions{
+		Process:   params.Get("process"),
+		Addr:      params.Get("addr"),
+		Direction: params.Get("direction"),
+		Limit:     DefaultLogSearchLimit,
+	}
+	var err error
+
+	if opts.From, err = parseTimeParam(params.Get("from")); err != nil {
+		return opts, err
+	}
+	if opts.To, err = parseTimeParam(params.Get("to")); err != nil {
+		return opts, err
+	}
+	if pid := params.Get("pid"); pid != "" {
+		n, err := strconv.ParseInt(pid, 10, 32)
+		if err != nil {
+			return opts, fmt.Errorf("无效的PID: %s", pid)
+		}
+		opts.PID = int32(n)
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit <= 0 {
+			return opts, fmt.Errorf("无效的limit: %s", limit)
+		}
+	}
+
+	// source 限定只搜索监听端口日志或已建立连接日志
+	source := params.Get("source")
+	for _, d := range s.logDirs {
+		if source == "" || strings.EqualFold(source, d.Source) {
+			opts.Dirs = append(opts.Dirs, d)
+		}
+	}
+	if len(opts.Dirs) == 0 {
+		return opts, fmt.Errorf("未知的日志来源: %s", source)
+	}
+	return opts, nil
+}
+
+// handleLogSearch 按时间范围、PID、进程、地址和事件方向搜索日志文件
+func (s *Server) handleLogSearch(w http.ResponseWriter, r *http.Request) {
+	if len(s.logDirs) == 0 {
+		http.Error(w, "Log search not enabled", http.StatusNotFound)
+		return
+	}
+
+	opts, err := s.parseLogSearch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 多取一条用于判断是否截断
+	limit := opts.Limit
+	opts.Limit++
+	resp := LogSearchResponse{Entries: []logger.Entry{}}
+	err = logger.Search(opts, func(e logger.Entry) bool {
+		if len(resp.Entries) >= limit {
+			resp.Truncated = true
+			return false
+		}
+		resp.Entries = append(resp.Entries, e)
+		return true
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"netmonitor/pkg/monitor"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// metricsWriter 按Prometheus文本格式(0.0.4)输出指标
+type metricsWriter struct {
+	b strings.Builder
+}
+
+// header 输出指标的 HELP 和 TYPE 行
+func (w *metricsWriter) header(name, typ, help string) {
+	fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample 输出一个样本,labels 为交替出现的标签名和标签值
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	w.b.WriteString(name)
+	if len(labels) > 0 {
+		w.b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.b.WriteByte(',')
+			}
+			fmt.Fprintf(&w.b, `%s="%s"`, labels[i], escapeLabel(labels[i+1]))
+		}
+		w.b.WriteByte('}')
+	}
+	w.b.WriteByte(' ')
+	w.b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.b.WriteByte('\n')
+}
+
+// counts 输出按单个标签分组的一组样本,标签值排序以保证输出稳定
+func (w *metricsWriter) counts(name, label string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.sample(name, float64(counts[k]), label, k)
+	}
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.lastConnsMu.RLock()
+	conns := s.lastConns
+	s.lastConnsMu.RUnlock()
+
+	byState := make(map[string]int)
+	byProtocol := make(map[string]int)
+	byProcess := make(map[string]int)
+	listeners := make(map[string]int)
+	for _, c := range conns {
+		byProtocol[c.Protocol]++
+		if c.Protocol == "TCP" {
+			byState[c.Status]++
+		}
+		name := c.ProcessName
+		if name == "" {
+			name = "unknown"
+		}
+		byProcess[name]++
+		if monitor.IsListeningPort(c) {
+			listeners[c.Protocol]++
+		}
+	}
+
+	var m metricsWriter
+
+	m.header("netmonitor_connections", "gauge", "Current sockets by protocol.")
+	m.counts("netmonitor_connections", "protocol", byProtocol)
+	m.header("netmonitor_tcp_connections", "gauge", "Current TCP sockets by state.")
+	m.counts("netmonitor_tcp_connections", "state", byState)
+	m.header("netmonitor_process_connections", "gauge", "Current sockets by process name.")
+	m.counts("netmonitor_process_connections", "process", byProcess)
+	m.header("netmonitor_listeners", "gauge", "Current listening endpoints by protocol.")
+	m.counts("netmonitor_listeners", "protocol", listeners)
+
+	if s.stats != nil {
+		c := s.stats.GetCounters()
+		m.header("netmonitor_connections_opened_total", "counter", "Established connections opened since start.")
+		m.sample("netmonitor_connections_opened_total", float64(c.NewConnections))
+		m.header("netmonitor_connections_closed_total", "counter", "Established connections closed since start.")
+		m.sample("netmonitor_connections_closed_total", float64(c.ClosedConnections))
+		m.header("netmonitor_listeners_opened_total", "counter", "Listening endpoints opened since start.")
+		m.sample("netmonitor_listeners_opened_total", float64(c.NewListeners))
+		m.header("netmonitor_listeners_closed_total", "counter", "Listening endpoints closed since start.")
+		m.sample("netmonitor_listeners_closed_total", float64(c.ClosedListeners))
+		m.header("netmonitor_listener_owner_changes_total", "counter", "Listening endpoints rebound by another process.")
+		m.sample("netmonitor_listener_owner_changes_total", float64(c.OwnerChanges))
+		m.header("netmonitor_state_changes_total", "counter", "Tracked TCP state transitions.")
+		m.sample("netmonitor_state_changes_total", float64(c.StateChanges))
+		m.header("netmonitor_alerts_total", "counter", "Alerts raised since start.")
+		m.sample("netmonitor_alerts_total", float64(c.Alerts))
+
+		m.header("netmonitor_collections_total", "counter", "Connection table collections.")
+		m.sample("netmonitor_collections_total", float64(c.Collections))
+		m.header("netmonitor_collection_errors_total", "counter", "Failed connection table collections.")
+		m.sample("netmonitor_collection_errors_total", float64(c.CollectErrors))
+		m.header("netmonitor_collection_duration_seconds", "gauge", "Duration of the last collection.")
+		m.sample("netmonitor_collection_duration_seconds", c.CollectDuration.Seconds())
+		m.header("netmonitor_collection_duration_seconds_total", "counter", "Total time spent collecting.")
+		m.sample("netmonitor_collection_duration_seconds_total", c.CollectTotal.Seconds())
+	}
+
+	if s.bus != nil {
+		subs := s.bus.Stats()
+		sort.Slice(subs, func(i, j int) bool { return subs[i].Name < subs[j].Name })
+		m.header("netmonitor_events_delivered_total", "counter", "Events delivered to each bus subscriber.")
+		for _, st := range subs {
+			m.sample("netmonitor_events_delivered_total", float64(st.Delivered), "subscriber", st.Name)
+		}
+		m.header("netmonitor_events_dropped_total", "counter", "Events dropped because a subscriber queue was full.")
+		for _, st := range subs {
+			m.sample("netmonitor_events_dropped_total", float64(st.Dropped), "subscriber", st.Name)
+		}
+	}
+
+	s.clientsMu.RLock()
+	clients := len(s.clients)
+	s.clientsMu.RUnlock()
+	m.header("netmonitor_websocket_clients", "gauge", "Connected WebSocket clients.")
+	m.sample("netmonitor_websocket_clients", float64(clients))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(m.b.String()))
+}
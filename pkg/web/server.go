@@ -1,12 +1,19 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"netmonitor/pkg/event"
+	"netmonitor/pkg/history"
+	"netmonitor/pkg/logger"
 	"netmonitor/pkg/monitor"
 	"netmonitor/pkg/netinfo"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -21,74 +28,207 @@ var upgrader = websocket.Upgrader{
 type Server struct {
 	port        int
 	stats       *monitor.Stats
-	filter      *netinfo.ConnectionFilter
+	bus         *event.Bus
+	history     *history.Store
+	logDirs     []logger.SearchDir
+	filter      atomic.Pointer[netinfo.ConnectionFilter]
+	httpServer  *http.Server
+	httpMu      sync.Mutex
+	broadcastGo sync.Once
 	clients     map[*websocket.Conn]bool
 	clientsMu   sync.RWMutex
 	broadcast   chan []byte
 	lastConns   []netinfo.Connection
+	lastUpdate  time.Time
 	lastConnsMu sync.RWMutex
 }
 
 type ConnectionEvent struct {
-	Type        string    `json:"type"`
-	Protocol    string    `json:"protocol"`
-	LocalAddr   string    `json:"local_addr"`
-	RemoteAddr  string    `json:"remote_addr"`
-	PID         int32     `json:"pid"`
-	ProcessName string    `json:"process_name"`
-	Timestamp   time.Time `json:"timestamp"`
+	Type           string    `json:"type"`
+	Kind           string    `json:"kind"` // 事件总线中的事件类型
+	Family         string    `json:"family"`
+	Protocol       string    `json:"protocol"`
+	LocalAddr      string    `json:"local_addr"`
+	RemoteAddr     string    `json:"remote_addr"`
+	PID            int32     `json:"pid"`
+	ProcessName    string    `json:"process_name"`
+	OldPID         int32     `json:"old_pid,omitempty"`          // 仅 owner_changed 事件
+	OldProcessName string    `json:"old_process_name,omitempty"` // 仅 owner_changed 事件
+	Lifetime       float64   `json:"lifetime,omitempty"`         // 存活秒数,仅已建立连接的 closed 事件
+	LifetimeText   string    `json:"lifetime_text,omitempty"`    // 存活时长的可读形式
+	FromState      string    `json:"from_state,omitempty"`       // 仅 state_changed 事件
+	ToState        string    `json:"to_state,omitempty"`         // 仅 state_changed 事件
+	Message        string    `json:"message,omitempty"`          // 仅 alert 事件
+	Timestamp      time.Time `json:"timestamp"`
 }
 
 type StatsData struct {
-	TotalConnections  int               `json:"total_connections"`
-	TotalListeners    int               `json:"total_listeners"`
-	NewConnections    int               `json:"new_connections"`
-	ClosedConnections int               `json:"closed_connections"`
-	ByProtocol        map[string]int    `json:"by_protocol"`
-	ByPID             map[int32]int     `json:"by_pid"`
-	LastUpdate        time.Time         `json:"last_update"`
+	TotalConnections  int                      `json:"total_connections"`
+	TotalListeners    int                      `json:"total_listeners"`
+	NewConnections    int                      `json:"new_connections"`
+	ClosedConnections int                      `json:"closed_connections"`
+	ByProtocol        map[string]int           `json:"by_protocol"`
+	ByFamily          map[string]int           `json:"by_family"`
+	ByPID             map[int32]int            `json:"by_pid"`
+	ByState           map[string]int           `json:"by_state"`
+	LifetimeHistogram []monitor.LifetimeBucket `json:"lifetime_histogram"`
+	LastUpdate        time.Time                `json:"last_update"`
 }
 
 type ConnectionResponse struct {
 	LocalAddr   string `json:"local_addr"`
 	RemoteAddr  string `json:"remote_addr"`
+	Family      string `json:"family"`
 	Protocol    string `json:"protocol"`
 	Status      string `json:"status"`
 	PID         int32  `json:"pid"`
 	ProcessName string `json:"process_name"`
+	UID         uint32 `json:"uid"`
+	Inode       uint64 `json:"inode,omitempty"`
+	RxQueue     uint32 `json:"rx_queue"`
+	TxQueue     uint32 `json:"tx_queue"`
+}
+
+func newConnectionResponse(conn netinfo.Connection) ConnectionResponse {
+	return ConnectionResponse{
+		LocalAddr:   netinfo.FormatAddr(conn.LocalAddr),
+		RemoteAddr:  netinfo.FormatAddr(conn.RemoteAddr),
+		Family:      conn.Family,
+		Protocol:    conn.Protocol,
+		Status:      conn.Status,
+		PID:         conn.PID,
+		ProcessName: conn.ProcessName,
+		UID:         conn.UID,
+		Inode:       conn.Inode,
+		RxQueue:     conn.RxQueue,
+		TxQueue:     conn.TxQueue,
+	}
+}
+
+// eventTypes 事件类型到页面事件类型的映射
+var eventTypes = map[event.Kind]string{
+	event.ListenerOpened:       "new",
+	event.ListenerClosed:       "closed",
+	event.ListenerOwnerChanged: "owner_changed",
+	event.ConnOpened:           "new",
+	event.ConnClosed:           "closed",
+	event.StateChanged:         "state_changed",
+	event.Alert:                "alert",
+}
+
+func newConnectionEvent(e event.Event) ConnectionEvent {
+	conn := e.Conn
+	ev := ConnectionEvent{
+		Type:        eventTypes[e.Kind],
+		Kind:        string(e.Kind),
+		Family:      conn.Family,
+		Protocol:    conn.Protocol,
+		LocalAddr:   netinfo.FormatAddr(conn.LocalAddr),
+		RemoteAddr:  netinfo.FormatAddr(conn.RemoteAddr),
+		PID:         conn.PID,
+		ProcessName: conn.ProcessName,
+		Timestamp:   e.Timestamp,
+	}
+
+	switch e.Kind {
+	case event.ListenerOwnerChanged:
+		ev.OldPID = int32(e.Int(event.MetaOldPID))
+		ev.OldProcessName = e.Meta(event.MetaOldProcessName)
+	case event.ConnClosed:
+		ev.Lifetime = e.Duration(event.MetaLifetime).Seconds()
+		ev.LifetimeText = e.LifetimeString()
+	case event.StateChanged:
+		ev.FromState = e.Meta(event.MetaFromState)
+		ev.ToState = e.Meta(event.MetaToState)
+	case event.Alert:
+		ev.Message = e.Meta(event.MetaMessage)
+	}
+	return ev
 }
 
 func NewServer(port int) *Server {
-	return &Server{
+	s := &Server{
 		port:      port,
 		clients:   make(map[*websocket.Conn]bool),
 		broadcast: make(chan []byte, 100),
-		filter:    &netinfo.ConnectionFilter{},
 	}
+	s.filter.Store(&netinfo.ConnectionFilter{})
+	return s
 }
 
 func (s *Server) SetStats(stats *monitor.Stats) {
 	s.stats = stats
 }
 
+// SetBus 设置事件总线,用于导出各订阅者的投递统计
+func (s *Server) SetBus(bus *event.Bus) {
+	s.bus = bus
+}
+
+// SetFilter 替换连接列表使用的过滤器,运行中调用时对后续请求生效
 func (s *Server) SetFilter(filter *netinfo.ConnectionFilter) {
 	if filter != nil {
-		s.filter = filter
+		s.filter.Store(filter)
 	}
 }
 
+// SetPort 修改监听端口,在下一次 Start 时生效
+func (s *Server) SetPort(port int) {
+	s.httpMu.Lock()
+	s.port = port
+	s.httpMu.Unlock()
+}
+
+// Start 开始监听并阻塞到服务器出错或被 Stop 关闭;被 Stop 关闭时返回 nil
 func (s *Server) Start() error {
-	go s.handleBroadcast()
+	s.broadcastGo.Do(func() { go s.handleBroadcast() })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/api/stats", s.handleStats)
+	mux.HandleFunc("/api/connections", s.handleConnections)
+	mux.HandleFunc("/api/history", s.handleHistory)
+	mux.HandleFunc("/api/history/snapshot", s.handleHistorySnapshot)
+	mux.HandleFunc("/api/logs/search", s.handleLogSearch)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+
+	s.httpMu.Lock()
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", s.port), Handler: mux}
+	s.httpServer = srv
+	port := s.port
+	s.httpMu.Unlock()
+
+	fmt.Printf("Web界面已启动: http://localhost:%d\n", port)
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
 
-	http.HandleFunc("/", s.handleIndex)
-	http.HandleFunc("/api/stats", s.handleStats)
-	http.HandleFunc("/api/connections", s.handleConnections)
-	http.HandleFunc("/ws", s.handleWebSocket)
+// Stop 关闭监听和所有WebSocket连接,等待进行中的请求完成(最多5秒)
+func (s *Server) Stop() error {
+	s.httpMu.Lock()
+	srv := s.httpServer
+	s.httpServer = nil
+	s.httpMu.Unlock()
+	if srv == nil {
+		return nil
+	}
 
-	addr := fmt.Sprintf(":%d", s.port)
-	fmt.Printf("Web界面已启动: http://localhost:%d\n", s.port)
+	// WebSocket连接已被接管,Shutdown 不会关闭它们
+	s.clientsMu.Lock()
+	for client := range s.clients {
+		client.Close()
+		delete(s.clients, client)
+	}
+	s.clientsMu.Unlock()
 
-	return http.ListenAndServe(addr, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -106,22 +246,31 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		TotalListeners:    0,
 		NewConnections:    s.stats.GetRecentNewCount(),
 		ClosedConnections: s.stats.GetRecentClosedCount(),
+		LifetimeHistogram: s.stats.GetLifetimeHistogram(),
+		ByState:           s.stats.GetStateCounts(),
 		ByProtocol:        make(map[string]int),
+		ByFamily:          make(map[string]int),
 		ByPID:             make(map[int32]int),
-		LastUpdate:        time.Now(),
 	}
 
 	s.lastConnsMu.RLock()
 	conns := s.lastConns
+	statsData.LastUpdate = s.lastUpdate
 	s.lastConnsMu.RUnlock()
 
+	// 与连接列表使用相同的过滤条件
+	filter := s.filter.Load()
 	for _, conn := range conns {
+		if filter.ShouldFilter(conn) {
+			continue
+		}
 		if conn.Status == "ESTABLISHED" {
 			statsData.TotalConnections++
 		} else if conn.Status == "LISTEN" {
 			statsData.TotalListeners++
 		}
 		statsData.ByProtocol[conn.Protocol]++
+		statsData.ByFamily[conn.Family]++
 		if conn.PID > 0 {
 			statsData.ByPID[conn.PID]++
 		}
@@ -131,23 +280,37 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(statsData)
 }
 
+// handleConnections 当前连接列表,在配置的过滤条件之外进一步筛选:
+// q 参数为过滤表达式,remote 参数为逗号分隔的远程IP或CIDR网段
 func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	query := &netinfo.ConnectionFilter{}
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		expr, err := netinfo.ParseFilterExpr(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Expr = expr
+	}
+	if remote := strings.TrimSpace(r.URL.Query().Get("remote")); remote != "" {
+		nets, err := netinfo.ParsePrefixes(strings.Split(remote, ","))
+		if err != nil {
+			http.Error(w, "远程地址: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.RemoteNets = nets
+	}
+
 	s.lastConnsMu.RLock()
 	allConns := s.lastConns
 	s.lastConnsMu.RUnlock()
 
 	// 应用过滤
+	filter := s.filter.Load()
 	var filteredConns []ConnectionResponse
 	for _, conn := range allConns {
-		if s.filter == nil || !s.filter.ShouldFilter(conn) {
-			filteredConns = append(filteredConns, ConnectionResponse{
-				LocalAddr:   conn.LocalAddr,
-				RemoteAddr:  conn.RemoteAddr,
-				Protocol:    conn.Protocol,
-				Status:      conn.Status,
-				PID:         conn.PID,
-				ProcessName: conn.ProcessName,
-			})
+		if !filter.ShouldFilter(conn) && !query.ShouldFilter(conn) {
+			filteredConns = append(filteredConns, newConnectionResponse(conn))
 		}
 	}
 
@@ -207,42 +370,11 @@ func (s *Server) handleBroadcast() {
 	}
 }
 
-func (s *Server) BroadcastNewConnection(conn netinfo.Connection) {
-	event := ConnectionEvent{
-		Type:        "new",
-		Protocol:    conn.Protocol,
-		LocalAddr:   conn.LocalAddr,
-		RemoteAddr:  conn.RemoteAddr,
-		PID:         conn.PID,
-		ProcessName: conn.ProcessName,
-		Timestamp:   time.Now(),
-	}
-
-	data, _ := json.Marshal(map[string]interface{}{
-		"type": "event",
-		"data": event,
-	})
-
-	select {
-	case s.broadcast <- data:
-	default:
-	}
-}
-
-func (s *Server) BroadcastClosedConnection(conn netinfo.Connection) {
-	event := ConnectionEvent{
-		Type:        "closed",
-		Protocol:    conn.Protocol,
-		LocalAddr:   conn.LocalAddr,
-		RemoteAddr:  conn.RemoteAddr,
-		PID:         conn.PID,
-		ProcessName: conn.ProcessName,
-		Timestamp:   time.Now(),
-	}
-
+// HandleEvent 将总线上的事件广播给WebSocket客户端,作为事件总线的订阅者使用
+func (s *Server) HandleEvent(e event.Event) {
 	data, _ := json.Marshal(map[string]interface{}{
 		"type": "event",
-		"data": event,
+		"data": newConnectionEvent(e),
 	})
 
 	select {
@@ -251,13 +383,15 @@ func (s *Server) BroadcastClosedConnection(conn netinfo.Connection) {
 	}
 }
 
-func (s *Server) UpdateConnections(conns []netinfo.Connection) {
+// UpdateConnections 使用本轮快照更新连接列表
+func (s *Server) UpdateConnections(snap *netinfo.Snapshot) {
 	s.lastConnsMu.Lock()
-	s.lastConns = conns
+	s.lastConns = snap.Connections
+	s.lastUpdate = snap.Timestamp
 	s.lastConnsMu.Unlock()
 
 	// 广播完整连接列表
-	data := s.buildConnectionsMessage(conns)
+	data := s.buildConnectionsMessage(snap.Connections)
 
 	select {
 	case s.broadcast <- data:
@@ -267,17 +401,11 @@ func (s *Server) UpdateConnections(conns []netinfo.Connection) {
 
 func (s *Server) buildConnectionsMessage(conns []netinfo.Connection) []byte {
 	// 应用过滤
+	filter := s.filter.Load()
 	var filteredConns []ConnectionResponse
 	for _, conn := range conns {
-		if s.filter == nil || !s.filter.ShouldFilter(conn) {
-			filteredConns = append(filteredConns, ConnectionResponse{
-				LocalAddr:   conn.LocalAddr,
-				RemoteAddr:  conn.RemoteAddr,
-				Protocol:    conn.Protocol,
-				Status:      conn.Status,
-				PID:         conn.PID,
-				ProcessName: conn.ProcessName,
-			})
+		if !filter.ShouldFilter(conn) {
+			filteredConns = append(filteredConns, newConnectionResponse(conn))
 		}
 	}
 